@@ -6,134 +6,142 @@ import (
 	"database/sql"
 	"encoding/csv"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-func runExtractionForSol(ctx context.Context, db *sql.DB, solID string, procConfig *ExtractionConfig, templates map[string][]ColumnConfig, logCh chan<- ProcLog, mu *sync.Mutex, summary map[string]ProcSummary) {
-	var wg sync.WaitGroup
-	procCh := make(chan string)
-
-	numWorkers := runtime.NumCPU() * 2
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for proc := range procCh {
-				start := time.Now()
-				log.Printf("📥 Extracting %s for SOL %s", proc, solID)
-				err := extractData(ctx, db, proc, solID, procConfig, templates)
-				end := time.Now()
-
-				plog := ProcLog{
-					SolID:         solID,
-					Procedure:     proc,
-					StartTime:     start,
-					EndTime:       end,
-					ExecutionTime: end.Sub(start),
-				}
-				if err != nil {
-					plog.Status = "FAIL"
-					plog.ErrorDetails = err.Error()
-				} else {
-					plog.Status = "SUCCESS"
-				}
-				logCh <- plog
-
-				mu.Lock()
-				s, exists := summary[proc]
-				if !exists {
-					s = ProcSummary{Procedure: proc, StartTime: start, EndTime: end, Status: plog.Status}
-				} else {
-					if start.Before(s.StartTime) {
-						s.StartTime = start
-					}
-					if end.After(s.EndTime) {
-						s.EndTime = end
-					}
-					if s.Status != "FAIL" && plog.Status == "FAIL" {
-						s.Status = "FAIL"
-					}
-				}
-				summary[proc] = s
-				mu.Unlock()
-				log.Printf("✅ Completed %s for SOL %s in %s", proc, solID, end.Sub(start).Round(time.Millisecond))
-			}
-		}()
-	}
-
-	for _, proc := range procConfig.Procedures {
-		procCh <- proc
-	}
-	close(procCh)
-	wg.Wait()
-}
-
-func extractData(ctx context.Context, db *sql.DB, procName, solID string, cfg *ExtractionConfig, templates map[string][]ColumnConfig) error {
+// extractData writes one SOL's rows for a procedure to its spool file and
+// returns the number of rows and bytes written. A failed extraction removes the
+// partial spool so neither a retry nor the merge sees it.
+func extractData(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, solID string, templates map[string][]ColumnConfig, queries map[string]string) (rowCount, byteCount int64, err error) {
+	procName := proc.Name
 	cols, ok := templates[procName]
 	if !ok {
-		return fmt.Errorf("missing template for procedure %s", procName)
+		return 0, 0, fmt.Errorf("missing template for procedure %s", procName)
 	}
 
-	colNames := make([]string, len(cols))
-	for i, col := range cols {
-		colNames[i] = col.Name
+	query := extractQuery(proc, cols, queries)
+	args, err := bindArgs(cfg, proc, query, solID)
+	if err != nil {
+		return 0, 0, err
 	}
-
-	query := fmt.Sprintf("SELECT %s FROM %s WHERE SOL_ID = :1", strings.Join(colNames, ", "), procName)
 	start := time.Now()
-	rows, err := db.QueryContext(ctx, query, solID)
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("query failed: %w", err)
+		return 0, 0, fmt.Errorf("query failed: %w", err)
 	}
 	defer rows.Close()
+
+	queryCols, err := rows.Columns()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading result columns failed: %w", err)
+	}
+	if err := matchColumns(procName, cols, queryCols); err != nil {
+		return 0, 0, err
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, 0, fmt.Errorf("reading result column types failed: %w", err)
+	}
+	kinds := resultKinds(cols, colTypes)
 	log.Printf("🧮 Query executed for %s (SOL %s) in %s", procName, solID, time.Since(start).Round(time.Millisecond))
 
-	spoolPath := filepath.Join(cfg.SpoolOutputPath, fmt.Sprintf("%s_%s.spool", procName, solID))
+	spoolPath := spoolFilePath(proc, solID)
 	f, err := os.Create(spoolPath)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
+	defer func() {
+		if err != nil {
+			os.Remove(spoolPath)
+		}
+	}()
 	defer f.Close()
 
 	buf := bufio.NewWriter(f)
-	defer buf.Flush()
+	if proc.Format == "parquet" {
+		// The merge step writes the parquet file from the typed records,
+		// using the schema recorded on the first line.
+		line, err := parquetSchemaLine(parquetSchema(cols, colTypes, kinds))
+		if err != nil {
+			return 0, 0, err
+		}
+		buf.WriteString(line + "\n")
+	}
 
 	for rows.Next() {
-		values := make([]sql.NullString, len(cols))
-		scanArgs := make([]interface{}, len(cols))
-		for i := range values {
-			scanArgs[i] = &values[i]
-		}
-		if err := rows.Scan(scanArgs...); err != nil {
-			return err
+		values, err := scanTyped(rows, kinds, colTypes)
+		if err != nil {
+			return rowCount, byteCount, err
 		}
-		var strValues []string
-		for _, v := range values {
-			if v.Valid {
-				strValues = append(strValues, v.String)
-			} else {
-				strValues = append(strValues, "")
+		var rec string
+		if proc.Format == "jsonl" || proc.Format == "parquet" {
+			if rec, err = jsonRecord(cols, values); err != nil {
+				return rowCount, byteCount, err
 			}
+		} else if rec, err = formatRow(proc, cols, values, kinds); err != nil {
+			return rowCount, byteCount, err
 		}
-		buf.WriteString(formatRow(cfg, cols, strValues) + "\n")
+		n, _ := buf.WriteString(rec + recordTerminator(proc))
+		rowCount++
+		byteCount += int64(n)
 	}
-	return nil
+	if err := rows.Err(); err != nil {
+		return rowCount, byteCount, fmt.Errorf("fetching rows failed: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return rowCount, byteCount, err
+	}
+	// The checkpoint vouches for this spool once the task succeeds, so it
+	// must be on disk before then.
+	return rowCount, byteCount, f.Sync()
+}
+
+// extractQuery returns the statement extracting a procedure's rows: its SQL file
+// when it has one, otherwise a SELECT of the template columns from its source.
+func extractQuery(proc *ProcedureConfig, cols []ColumnConfig, queries map[string]string) string {
+	if query, ok := queries[proc.Name]; ok {
+		return query
+	}
+	colNames := make([]string, len(cols))
+	for i, col := range cols {
+		colNames[i] = col.Name
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(colNames, ", "), proc.Source)
+	var conds []string
+	if !proc.Global {
+		conds = append(conds, partitionFilter(proc))
+	}
+	if proc.Filter != "" {
+		conds = append(conds, "("+proc.Filter+")")
+	}
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	return query
+}
+
+// spoolFilePath is where one SOL's extract of a procedure is written before merging.
+func spoolFilePath(proc *ProcedureConfig, solID string) string {
+	return filepath.Join(proc.OutputPath, fmt.Sprintf("%s_%s.spool", proc.Name, solFileName(solID)))
 }
 
-func mergeFiles(cfg *ExtractionConfig) error {
-	for _, proc := range cfg.Procedures {
-		log.Printf("📦 Starting merge for procedure: %s", proc)
+// mergeFiles concatenates the spool files of the given procedures into their
+// output files, after a header record where the format asks for one; parquet
+// spools are combined into a single parquet file instead. The spools are left
+// in place; see removeSpoolFiles.
+func mergeFiles(procs []ProcedureConfig, templates map[string][]ColumnConfig) error {
+	for _, proc := range procs {
+		log.Printf("📦 Starting merge for procedure: %s", proc.Name)
 
-		pattern := filepath.Join(cfg.SpoolOutputPath, fmt.Sprintf("%s_*.spool", proc))
-		finalFile := filepath.Join(cfg.SpoolOutputPath, fmt.Sprintf("%s.txt", proc))
+		pattern := filepath.Join(proc.OutputPath, fmt.Sprintf("%s_*.spool", proc.Name))
+		finalFile := filepath.Join(proc.OutputPath, proc.FileName)
 
 		files, err := filepath.Glob(pattern)
 		if err != nil {
@@ -141,31 +149,68 @@ func mergeFiles(cfg *ExtractionConfig) error {
 		}
 		sort.Strings(files)
 
-		outFile, err := os.Create(finalFile)
+		start := time.Now()
+		err = writeOutputFile(finalFile, func(w io.Writer) error {
+			if proc.Format == "parquet" {
+				return mergeParquet(&proc, files, templates[proc.Name], w)
+			}
+			writer := bufio.NewWriter(w)
+			if proc.hasHeader() {
+				writer.WriteString(csvHeader(&proc, templates[proc.Name]) + recordTerminator(&proc))
+			}
+			for _, file := range files {
+				in, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(writer, in)
+				in.Close()
+				if err != nil {
+					return fmt.Errorf("%s: %w", file, err)
+				}
+			}
+			return writer.Flush()
+		})
 		if err != nil {
-			return err
+			return fmt.Errorf("merging %s failed: %w", proc.Name, err)
 		}
-		defer outFile.Close()
+		log.Printf("📑 Merged %d files into %s in %s", len(files), finalFile, time.Since(start).Round(time.Second))
+	}
+	return nil
+}
 
-		writer := bufio.NewWriter(outFile)
-		start := time.Now()
+// writeOutputFile writes an output file through a temporary file in the same
+// directory, renamed into place only once it is completely written, so a
+// failed merge never leaves a truncated file under the final name.
+func writeOutputFile(path string, write func(io.Writer) error) (err error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
 
+// removeSpoolFiles deletes the spool files of the given procedures.
+func removeSpoolFiles(procs []ProcedureConfig) {
+	for _, proc := range procs {
+		files, _ := filepath.Glob(filepath.Join(proc.OutputPath, fmt.Sprintf("%s_*.spool", proc.Name)))
 		for _, file := range files {
-			in, err := os.Open(file)
-			if err != nil {
-				return err
-			}
-			scanner := bufio.NewScanner(in)
-			for scanner.Scan() {
-				writer.WriteString(scanner.Text() + "\n")
-			}
-			in.Close()
 			os.Remove(file)
 		}
-		writer.Flush()
-		log.Printf("📑 Merged %d files into %s in %s", len(files), finalFile, time.Since(start).Round(time.Second))
 	}
-	return nil
 }
 
 func readColumnsFromCSV(path string) ([]ColumnConfig, error) {
@@ -198,27 +243,116 @@ func readColumnsFromCSV(path string) ([]ColumnConfig, error) {
 		if i, ok := index["align"]; ok && i < len(row) {
 			col.Align = row[i]
 		}
+		if err := readColumnFormat(&col, row, index); err != nil {
+			return nil, fmt.Errorf("%s: column %s: %w", path, col.Name, err)
+		}
 		cols = append(cols, col)
 	}
 	return cols, nil
 }
 
+// readQueryFromFile loads a procedure's extraction query. The query must be a
+// single SELECT (or WITH) statement, taking the partition key as bind variables
+// unless the procedure is global. Numbered binds must match the partition keys
+// and parameters one for one.
+func readQueryFromFile(proc *ProcedureConfig) (string, error) {
+	path := proc.SQLFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	query := strings.TrimSpace(string(data))
+	query = strings.TrimSpace(strings.TrimRight(query, ";/"))
+	if query == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	if kw := leadingKeyword(query); kw != "SELECT" && kw != "WITH" {
+		return "", fmt.Errorf("%s must contain a SELECT statement", path)
+	}
+	binds := statementBinds(query)
+	if !proc.Global && len(binds) == 0 {
+		return "", fmt.Errorf("%s has no bind variable for the partition key", path)
+	}
+	if len(binds) > 0 && isPositional(binds[0]) {
+		want := len(proc.Params)
+		if !proc.Global {
+			want += len(proc.PartitionKeys)
+		}
+		if len(binds) != want {
+			return "", fmt.Errorf("%s has %d bind variables but %s has %d partition keys and parameters",
+				path, len(binds), proc.Name, want)
+		}
+	}
+	return query, nil
+}
+
+// checkQueryColumns describes the query of every procedure extracted through
+// a SQL file and checks it against the procedure's template, so a mismatch
+// stops the run before any SOL is extracted.
+func checkQueryColumns(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, sols []string, templates map[string][]ColumnConfig, queries map[string]string) error {
+	for i := range cfg.Procedures {
+		proc := &cfg.Procedures[i]
+		if _, ok := queries[proc.Name]; !ok || (!proc.Global && len(sols) == 0) {
+			continue
+		}
+		dbCols, err := sourceColumns(ctx, db, cfg, proc, sols, queries)
+		if err != nil {
+			return fmt.Errorf("%s: %w", proc.Name, err)
+		}
+		names := make([]string, len(dbCols))
+		for j, c := range dbCols {
+			names[j] = c.Name
+		}
+		if err := matchColumns(proc.Name, templates[proc.Name], names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchColumns checks that a query returns exactly the columns its template describes.
+func matchColumns(procName string, cols []ColumnConfig, queryCols []string) error {
+	if len(queryCols) != len(cols) {
+		return fmt.Errorf("column count mismatch for %s: query returns %d columns (%s) but template defines %d",
+			procName, len(queryCols), strings.Join(queryCols, ", "), len(cols))
+	}
+	return nil
+}
+
 func sanitize(s string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "\r", " ")
 }
 
-func formatRow(cfg *ExtractionConfig, cols []ColumnConfig, values []string) string {
-	switch cfg.Format {
+// formatRow renders one row of scanned values in the procedure's format.
+// Numbers that do not fit their fixed-width column are an error.
+func formatRow(proc *ProcedureConfig, cols []ColumnConfig, values []interface{}, kinds []columnKind) (string, error) {
+	text := make([]string, len(values))
+	numeric := make([]bool, len(values))
+	for i, v := range values {
+		text[i] = cols[i].formatValue(v)
+		numeric[i] = kinds[i] == numberKind
+	}
+	switch proc.Format {
+	case "csv":
+		return csvRecord(proc, text, numeric), nil
 	case "delimited":
 		var parts []string
-		for _, v := range values {
+		for _, v := range text {
 			parts = append(parts, sanitize(v))
 		}
-		return strings.Join(parts, cfg.Delimiter)
+		return strings.Join(parts, proc.Delimiter), nil
 	case "fixed":
 		var out strings.Builder
 		for i, col := range cols {
-			val := sanitize(values[i])
+			if numeric[i] && values[i] != nil {
+				field, err := col.fixedNumber(values[i])
+				if err != nil {
+					return "", err
+				}
+				out.WriteString(field)
+				continue
+			}
+			val := sanitize(text[i])
 			if len(val) > col.Length {
 				val = val[:col.Length]
 			}
@@ -228,8 +362,8 @@ func formatRow(cfg *ExtractionConfig, cols []ColumnConfig, values []string) stri
 				out.WriteString(fmt.Sprintf("%-*s", col.Length, val))
 			}
 		}
-		return out.String()
+		return out.String(), nil
 	default:
-		return ""
+		return "", nil
 	}
 }
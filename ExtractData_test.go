@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadQueryFromFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		proc  ProcedureConfig
+		err   string
+	}{
+		{"partition key", "SELECT A FROM T WHERE SOL_ID = :1;", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, ""},
+		{"key and params", "SELECT A FROM T WHERE SOL_ID = :1 AND D = :2", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}, Params: []string{"AS_OF_DATE"}}, ""},
+		{"named", "SELECT A FROM T WHERE SOL_ID = :SOL_ID", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, ""},
+		{"global", "SELECT A FROM T", ProcedureConfig{Global: true}, ""},
+		{"colon in literal", "SELECT TO_CHAR(D, 'HH24:MI:SS') FROM T", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, "no bind variable"},
+		{"missing param", "SELECT A FROM T WHERE SOL_ID = :1", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}, Params: []string{"AS_OF_DATE"}}, "has 1 bind variables but P has 2"},
+		{"leading comments", "-- RC002 extract\n/* one row per procedure */\nSELECT A FROM T WHERE SOL_ID = :1", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, ""},
+		{"hint", "/*+ leading comment */ select /*+ PARALLEL(4) */ A FROM T WHERE SOL_ID = :1", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, ""},
+		{"with clause", "with q as (SELECT A FROM T WHERE SOL_ID = :1) SELECT A FROM q", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, ""},
+		{"commented out query", "-- SELECT A FROM T\nDELETE FROM T WHERE SOL_ID = :1", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, "SELECT"},
+		{"not a query", "DELETE FROM T WHERE SOL_ID = :1", ProcedureConfig{PartitionKeys: []string{"SOL_ID"}}, "SELECT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.proc.Name = "P"
+			tt.proc.SQLFile = filepath.Join(t.TempDir(), "P.sql")
+			if err := os.WriteFile(tt.proc.SQLFile, []byte(tt.query), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := readQueryFromFile(&tt.proc)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadShippedQueries(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("sqls", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no SQL files found: %v", err)
+	}
+	for _, path := range paths {
+		proc := ProcedureConfig{Name: "P", SQLFile: path, PartitionKeys: []string{"SOL_ID"}}
+		if _, err := readQueryFromFile(&proc); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+}
+
+func TestMergeFilesFailureKeepsFinalName(t *testing.T) {
+	dir := t.TempDir()
+	proc := ProcedureConfig{Name: "P", Format: "parquet", OutputPath: dir, FileName: "P.parquet", RowGroupSize: 10}
+	other := append([]parquetColumn(nil), typedSchema...)
+	other[0].Kind = "string"
+	writeParquetSpool(t, dir, "P_01.spool", typedSchema)
+	writeParquetSpool(t, dir, "P_02.spool", other)
+	final := filepath.Join(dir, proc.FileName)
+	if err := os.WriteFile(final, []byte("previous run"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mergeFiles([]ProcedureConfig{proc}, nil); err == nil {
+		t.Fatal("merge of spools with different schemas succeeded")
+	}
+	if data, err := os.ReadFile(final); err != nil || string(data) != "previous run" {
+		t.Errorf("final file changed by a failed merge: %q, %v", data, err)
+	}
+	if _, err := os.Stat(final + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+
+	os.Remove(filepath.Join(dir, "P_02.spool"))
+	if err := mergeFiles([]ProcedureConfig{proc}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(final); err != nil || !strings.HasPrefix(string(data), "PAR1") {
+		t.Errorf("final file after a successful merge: %.8q, %v", data, err)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// statementBinds lists the distinct bind placeholders of a SQL statement or
+// PL/SQL block in order of first appearance, ignoring string literals, quoted
+// identifiers and comments.
+func statementBinds(stmt string) []string {
+	return scanBinds(stmt, true)
+}
+
+// bindOccurrences lists every bind placeholder of a statement in order,
+// repeats included.
+func bindOccurrences(stmt string) []string {
+	return scanBinds(stmt, false)
+}
+
+func scanBinds(stmt string, distinct bool) []string {
+	var binds []string
+	seen := make(map[string]bool)
+	for i := 0; i < len(stmt); i++ {
+		if end := commentEnd(stmt, i); end > i {
+			i = end - 1
+			continue
+		}
+		switch c := stmt[i]; {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(stmt[i+1:], c)
+			if end < 0 {
+				return binds
+			}
+			i += end + 1
+		case c == ':':
+			j := i + 1
+			for j < len(stmt) && isBindChar(rune(stmt[j])) {
+				j++
+			}
+			if j == i+1 {
+				continue
+			}
+			name := strings.ToUpper(stmt[i+1 : j])
+			if !distinct || !seen[name] {
+				seen[name] = true
+				binds = append(binds, name)
+			}
+			i = j - 1
+		}
+	}
+	return binds
+}
+
+// commentEnd returns the index just past a comment starting at stmt[i], or i
+// when no comment starts there.
+func commentEnd(stmt string, i int) int {
+	switch {
+	case strings.HasPrefix(stmt[i:], "--"):
+		end := strings.IndexByte(stmt[i:], '\n')
+		if end < 0 {
+			return len(stmt)
+		}
+		return i + end + 1
+	case strings.HasPrefix(stmt[i:], "/*"):
+		end := strings.Index(stmt[i+2:], "*/")
+		if end < 0 {
+			return len(stmt)
+		}
+		return i + end + 4
+	}
+	return i
+}
+
+// leadingKeyword returns the first word of a statement in upper case, after
+// any whitespace and comments.
+func leadingKeyword(stmt string) string {
+	i := 0
+	for i < len(stmt) {
+		if end := commentEnd(stmt, i); end > i {
+			i = end
+		} else if unicode.IsSpace(rune(stmt[i])) {
+			i++
+		} else {
+			break
+		}
+	}
+	j := i
+	for j < len(stmt) && isBindChar(rune(stmt[j])) {
+		j++
+	}
+	return strings.ToUpper(stmt[i:j])
+}
+
+// isPLSQLBlock reports whether a statement is an anonymous PL/SQL block, in
+// which a repeated placeholder is bound once rather than at each occurrence.
+func isPLSQLBlock(stmt string) bool {
+	kw := leadingKeyword(stmt)
+	return kw == "BEGIN" || kw == "DECLARE"
+}
+
+// checkNumberedBinds checks that numbered placeholders, listed in order of
+// first appearance, are :1 to :n. Oracle binds them by position, not by
+// their number, so any other order would silently swap values.
+func checkNumberedBinds(binds []string) error {
+	for i, b := range binds {
+		if b != strconv.Itoa(i+1) {
+			return fmt.Errorf("numbered bind variables must appear in order as :1 to :%d, but :%s comes in position %d",
+				len(binds), b, i+1)
+		}
+	}
+	return nil
+}
+
+func isBindChar(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '#'
+}
+
+func isPositional(bind string) bool {
+	return strings.IndexFunc(bind, func(r rune) bool { return !unicode.IsDigit(r) }) < 0
+}
+
+// bindArgs resolves the arguments for a statement run for one SOL. Numbered
+// placeholders, :1 to :n in order, take the partition key values followed by
+// the procedure's run parameters, once per occurrence in SQL; named
+// placeholders are looked up among the SOL list fields and the run parameters.
+func bindArgs(cfg *ExtractionConfig, proc *ProcedureConfig, stmt, solID string) ([]interface{}, error) {
+	binds := statementBinds(stmt)
+	if len(binds) == 0 {
+		return nil, nil
+	}
+	values, err := partitionValues(cfg, proc, solID)
+	if err != nil {
+		return nil, err
+	}
+
+	positional := 0
+	for _, b := range binds {
+		if isPositional(b) {
+			positional++
+		}
+	}
+	switch positional {
+	case len(binds):
+		if err := checkNumberedBinds(binds); err != nil {
+			return nil, fmt.Errorf("statement for %s: %w", proc.Name, err)
+		}
+		var args []interface{}
+		if !proc.Global {
+			for _, k := range proc.PartitionKeys {
+				args = append(args, values[parsePartitionKey(k).Field])
+			}
+		}
+		for _, name := range proc.Params {
+			args = append(args, cfg.Params[name])
+		}
+		if len(args) != len(binds) {
+			return nil, fmt.Errorf("statement for %s has %d bind variables but %d partition keys and parameters are configured",
+				proc.Name, len(binds), len(args))
+		}
+		if isPLSQLBlock(stmt) {
+			return args, nil
+		}
+		// SQL binds every occurrence by position, so a repeated number takes
+		// its value again.
+		occurrences := bindOccurrences(stmt)
+		repeated := make([]interface{}, len(occurrences))
+		for i, b := range occurrences {
+			n, _ := strconv.Atoi(b)
+			repeated[i] = args[n-1]
+		}
+		return repeated, nil
+	case 0:
+		args := make([]interface{}, 0, len(binds))
+		for _, name := range binds {
+			v, ok := values[name]
+			if !ok {
+				v, ok = cfg.Params[name]
+			}
+			if !ok {
+				return nil, fmt.Errorf("statement for %s references unknown bind variable :%s", proc.Name, name)
+			}
+			args = append(args, sql.Named(name, v))
+		}
+		return args, nil
+	default:
+		return nil, fmt.Errorf("statement for %s mixes numbered and named bind variables", proc.Name)
+	}
+}
+
+// callPlaceholders returns the bind placeholders passed to a procedure call:
+// its signature's arguments, or else the partition keys followed by the
+// procedure's run parameters.
+func callPlaceholders(proc *ProcedureConfig) string {
+	if len(proc.Signature) > 0 {
+		binds := make([]string, len(proc.Signature))
+		for i, a := range proc.Signature {
+			binds[i] = ":" + a.Name
+		}
+		return strings.Join(binds, ", ")
+	}
+	var binds []string
+	if !proc.Global {
+		for _, k := range proc.PartitionKeys {
+			binds = append(binds, parsePartitionKey(k).Field)
+		}
+	}
+	binds = append(binds, proc.Params...)
+	for i := range binds {
+		if proc.BindMode == "named" {
+			binds[i] = ":" + binds[i]
+		} else {
+			binds[i] = fmt.Sprintf(":%d", i+1)
+		}
+	}
+	return strings.Join(binds, ", ")
+}
+
+// applyParams merges command-line overrides into the run parameters and checks
+// that every parameter a procedure asks for is defined.
+func (cfg *ExtractionConfig) applyParams(overrides map[string]string) error {
+	params := make(map[string]string, len(cfg.Params)+len(overrides))
+	for k, v := range cfg.Params {
+		params[strings.ToUpper(k)] = v
+	}
+	for k, v := range overrides {
+		params[strings.ToUpper(k)] = v
+	}
+	cfg.Params = params
+
+	for i := range cfg.Procedures {
+		p := &cfg.Procedures[i]
+		for j, name := range p.Params {
+			p.Params[j] = strings.ToUpper(name)
+			if _, ok := params[p.Params[j]]; !ok {
+				return fmt.Errorf("procedure %s uses undefined parameter %s", p.Name, name)
+			}
+		}
+		binds := statementBinds(p.Filter)
+		for _, b := range binds {
+			if isPositional(b) {
+				return fmt.Errorf("filter of %s must reference parameters by name", p.Name)
+			}
+			if _, ok := params[b]; !ok {
+				return fmt.Errorf("filter of %s references undefined parameter %s", p.Name, b)
+			}
+		}
+		if len(binds) > 0 && !p.Global && p.BindMode != "named" {
+			return fmt.Errorf("filter of %s references parameters and requires bind_mode 'named'", p.Name)
+		}
+		for _, a := range p.Signature {
+			if a.Direction == "out" || (!p.Global && slices.Contains(cfg.SolFields, a.Name)) {
+				continue
+			}
+			if _, ok := params[a.Name]; !ok {
+				return fmt.Errorf("argument %s of %s is neither a SOL field nor a defined parameter", a.Name, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkQueryBinds checks the bind variables of the loaded SQL files: named
+// binds must be SOL fields, unless the procedure is global, or defined
+// parameters, and numbered and named binds cannot be mixed.
+func (cfg *ExtractionConfig) checkQueryBinds(queries map[string]string) error {
+	for _, p := range cfg.Procedures {
+		query, ok := queries[p.Name]
+		if !ok {
+			continue
+		}
+		binds := statementBinds(query)
+		positional := 0
+		for _, b := range binds {
+			if isPositional(b) {
+				positional++
+			}
+		}
+		if positional == len(binds) {
+			if err := checkNumberedBinds(binds); err != nil {
+				return fmt.Errorf("SQL file of %s: %w", p.Name, err)
+			}
+			continue
+		}
+		if positional > 0 {
+			return fmt.Errorf("SQL file of %s mixes numbered and named bind variables", p.Name)
+		}
+		for _, b := range binds {
+			if !p.Global && slices.Contains(cfg.SolFields, b) {
+				continue
+			}
+			if _, ok := cfg.Params[b]; !ok {
+				return fmt.Errorf("bind variable :%s in the SQL file of %s is neither a SOL field nor a defined parameter", b, p.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// formatParams renders the run parameters as NAME=VALUE pairs in name order.
+func formatParams(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, k := range names {
+		pairs[i] = k + "=" + params[k]
+	}
+	return strings.Join(pairs, ";")
+}
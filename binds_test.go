@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStatementBinds(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want []string
+	}{
+		{"SELECT * FROM T WHERE A = :1 AND B = :2", []string{"1", "2"}},
+		{"SELECT * FROM T WHERE A = :sol_id AND B = :SOL_ID", []string{"SOL_ID"}},
+		{"SELECT TO_CHAR(D, 'HH24:MI:SS') FROM T", nil},
+		{`SELECT "A:B" FROM T WHERE X = :1`, []string{"1"}},
+		{"SELECT 1 FROM T -- :IGNORED\nWHERE A = :A", []string{"A"}},
+		{"SELECT /* :IGNORED */ 1 FROM T WHERE A = :A", []string{"A"}},
+		{"BEGIN PKG.PROC(:1, :2, :1); END;", []string{"1", "2"}},
+		{"SELECT CAST(A AS NUMBER) FROM T WHERE B := 1", nil},
+	}
+	for _, tt := range tests {
+		if got := statementBinds(tt.stmt); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("statementBinds(%q) = %q, want %q", tt.stmt, got, tt.want)
+		}
+	}
+}
+
+func TestLeadingKeyword(t *testing.T) {
+	tests := []struct {
+		stmt, want string
+	}{
+		{"select 1 from dual", "SELECT"},
+		{"  \n\tWITH q AS (SELECT 1 FROM DUAL) SELECT * FROM q", "WITH"},
+		{"-- daily extract\n/* owner: ops */ SELECT 1 FROM DUAL", "SELECT"},
+		{"/*+ parallel(4) */SELECT 1 FROM DUAL", "SELECT"},
+		{"DECLARE n NUMBER; BEGIN NULL; END;", "DECLARE"},
+		{"-- only a comment", ""},
+	}
+	for _, tt := range tests {
+		if got := leadingKeyword(tt.stmt); got != tt.want {
+			t.Errorf("leadingKeyword(%q) = %q, want %q", tt.stmt, got, tt.want)
+		}
+	}
+}
+
+func TestBindArgs(t *testing.T) {
+	cfg := &ExtractionConfig{
+		SolFields: []string{"SOL_ID", "BRANCH"},
+		Params:    map[string]string{"AS_OF_DATE": "2024-03-31"},
+	}
+	proc := &ProcedureConfig{Name: "P", PartitionKeys: []string{"SOL_ID", "BR=BRANCH"}, Params: []string{"AS_OF_DATE"}}
+	tests := []struct {
+		name string
+		stmt string
+		want []interface{}
+		err  string
+	}{
+		{"none", "SELECT 1 FROM DUAL", nil, ""},
+		{"positional", "SELECT * FROM T WHERE S = :1 AND B = :2 AND D = :3",
+			[]interface{}{"01", "0007", "2024-03-31"}, ""},
+		{"named", "SELECT * FROM T WHERE D = :AS_OF_DATE AND S = :SOL_ID",
+			[]interface{}{sql.Named("AS_OF_DATE", "2024-03-31"), sql.Named("SOL_ID", "01")}, ""},
+		{"too few", "SELECT * FROM T WHERE S = :1", nil, "has 1 bind variables but 3"},
+		{"unknown", "SELECT * FROM T WHERE S = :SOLID", nil, "unknown bind variable :SOLID"},
+		{"mixed", "SELECT * FROM T WHERE S = :1 AND D = :AS_OF_DATE", nil, "mixes numbered and named"},
+		{"out of order", "SELECT * FROM T WHERE D = :3 AND S = :1 AND B = :2", nil, "but :3 comes in position 1"},
+		{"gap", "SELECT * FROM T WHERE S = :1 AND B = :2 AND D = :4", nil, "but :4 comes in position 3"},
+		{"repeated in SQL", "SELECT * FROM T WHERE (S = :1 OR P = :1) AND B = :2 AND D = :3 AND E >= :3",
+			[]interface{}{"01", "01", "0007", "2024-03-31", "2024-03-31"}, ""},
+		{"repeated in PL/SQL", "-- load\nBEGIN PKG.P(:1, :2, :3, :1); END;",
+			[]interface{}{"01", "0007", "2024-03-31"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bindArgs(cfg, proc, tt.stmt, "01,0007")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("args %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindArgsGlobal(t *testing.T) {
+	cfg := &ExtractionConfig{SolFields: []string{"SOL_ID"}, Params: map[string]string{"BATCH_ID": "7"}}
+	proc := &ProcedureConfig{Name: "G", Global: true, Params: []string{"BATCH_ID"}}
+	got, err := bindArgs(cfg, proc, "BEGIN PKG.G(:1); END;", globalSol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{"7"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("args %v, want %v", got, want)
+	}
+}
+
+func TestCheckQueryBinds(t *testing.T) {
+	cfg := &ExtractionConfig{
+		SolFields:  []string{"SOL_ID"},
+		Params:     map[string]string{"AS_OF_DATE": "2024-03-31"},
+		Procedures: []ProcedureConfig{{Name: "P"}, {Name: "G", Global: true}},
+	}
+	tests := []struct {
+		name    string
+		queries map[string]string
+		err     string
+	}{
+		{"numbered", map[string]string{"P": "SELECT A FROM T WHERE S = :1"}, ""},
+		{"sol field and param", map[string]string{"P": "SELECT A FROM T WHERE S = :sol_id AND D = :AS_OF_DATE"}, ""},
+		{"unknown", map[string]string{"P": "SELECT A FROM T WHERE S = :SOLID"}, ":SOLID in the SQL file of P"},
+		{"sol field in global", map[string]string{"G": "SELECT A FROM T WHERE S = :SOL_ID"}, ":SOL_ID in the SQL file of G"},
+		{"mixed", map[string]string{"P": "SELECT A FROM T WHERE S = :1 AND D = :AS_OF_DATE"}, "mixes numbered and named"},
+		{"out of order", map[string]string{"P": "SELECT A FROM T WHERE D = :2 AND S = :1"}, "but :2 comes in position 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cfg.checkQueryBinds(tt.queries)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// checkpoint durably records every (SOL, procedure) pair that completed
+// successfully so an interrupted run can be resumed without repeating them.
+// The file is CSV: a PARAMS record holding the run parameters, which a
+// resumed run must share, then one SOL,PROCEDURE,START,END,STATUS,ROWS,BYTES
+// record per pair.
+type checkpoint struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	writer *csv.Writer
+	done   map[string]ProcLog
+}
+
+func checkpointKey(solID, proc string) string {
+	return solID + "\x00" + proc
+}
+
+// openCheckpoint opens the checkpoint file at path for a run with the given
+// parameters. When resuming, pairs already recorded are loaded and new ones
+// appended, provided the checkpoint was written with the same parameters;
+// otherwise the file starts empty.
+func openCheckpoint(path string, resume bool, params string) (*checkpoint, error) {
+	cp := &checkpoint{path: path, done: make(map[string]ProcLog)}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	recorded, found := "", false
+	if resume {
+		var err error
+		if recorded, found, err = cp.load(); err != nil {
+			return nil, err
+		}
+		if found && recorded != params {
+			return nil, fmt.Errorf("%s was written with run parameters %q but this run has %q; rerun with the same parameters or without -resume",
+				path, recorded, params)
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cp.file = f
+	cp.writer = csv.NewWriter(f)
+	if found {
+		// Terminate a line torn by a crash so new entries start cleanly.
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 && data[len(data)-1] != '\n' {
+			f.WriteString("\n")
+		}
+		return cp, nil
+	}
+	cp.writer.Write([]string{"PARAMS", params})
+	cp.writer.Flush()
+	if err := cp.writer.Error(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cp, nil
+}
+
+// load reads the pairs recorded in the checkpoint file and returns the run
+// parameters it was written with; found is false when there is no file yet.
+func (cp *checkpoint) load() (params string, found bool, err error) {
+	f, err := os.Open(cp.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	rec, err := r.Read()
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil || len(rec) != 2 || rec[0] != "PARAMS" {
+		return "", false, fmt.Errorf("%s does not start with a PARAMS record; rerun without -resume", cp.path)
+	}
+	params = rec[1]
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return params, true, nil
+		}
+		// A line torn by a crash is skipped; that pair simply runs again.
+		var perr *csv.ParseError
+		if errors.As(err, &perr) || (err == nil && len(rec) != 7) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		start, err := time.Parse(time.RFC3339Nano, rec[2])
+		if err != nil {
+			return "", false, fmt.Errorf("invalid checkpoint entry %v: %w", rec, err)
+		}
+		end, err := time.Parse(time.RFC3339Nano, rec[3])
+		if err != nil {
+			return "", false, fmt.Errorf("invalid checkpoint entry %v: %w", rec, err)
+		}
+		plog := ProcLog{
+			SolID:         rec[0],
+			Procedure:     rec[1],
+			StartTime:     start,
+			EndTime:       end,
+			ExecutionTime: end.Sub(start),
+			Status:        rec[4],
+		}
+		if plog.Rows, err = strconv.ParseInt(rec[5], 10, 64); err != nil {
+			return "", false, fmt.Errorf("invalid checkpoint entry %v: %w", rec, err)
+		}
+		if plog.Bytes, err = strconv.ParseInt(rec[6], 10, 64); err != nil {
+			return "", false, fmt.Errorf("invalid checkpoint entry %v: %w", rec, err)
+		}
+		cp.done[checkpointKey(rec[0], rec[1])] = plog
+	}
+}
+
+// completed returns the recorded result for a pair finished in an earlier run.
+func (cp *checkpoint) completed(solID, proc string) (ProcLog, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	plog, ok := cp.done[checkpointKey(solID, proc)]
+	return plog, ok
+}
+
+// spoolMissing explains why a pair checkpointed by an earlier run must run
+// again: in extract mode, its spool file is gone or shorter than what was
+// written. It returns "" when the spool is intact or the mode writes none.
+func spoolMissing(mode string, proc *ProcedureConfig, prev ProcLog) string {
+	if mode != "E" {
+		return ""
+	}
+	info, err := os.Stat(spoolFilePath(proc, prev.SolID))
+	if err != nil {
+		return "its spool file is missing"
+	}
+	if info.Size() < prev.Bytes {
+		return fmt.Sprintf("its spool file has %d bytes but %d were written", info.Size(), prev.Bytes)
+	}
+	return ""
+}
+
+// record appends a successful pair and syncs it to disk before returning.
+func (cp *checkpoint) record(plog ProcLog) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.writer.Write([]string{
+		plog.SolID,
+		plog.Procedure,
+		plog.StartTime.Format(time.RFC3339Nano),
+		plog.EndTime.Format(time.RFC3339Nano),
+		plog.Status,
+		strconv.FormatInt(plog.Rows, 10),
+		strconv.FormatInt(plog.Bytes, 10),
+	})
+	cp.writer.Flush()
+	if err := cp.writer.Error(); err != nil {
+		return err
+	}
+	cp.done[checkpointKey(plog.SolID, plog.Procedure)] = plog
+	return cp.file.Sync()
+}
+
+func (cp *checkpoint) Close() error {
+	return cp.file.Close()
+}
+
+// remove deletes the checkpoint once a run has fully succeeded.
+func (cp *checkpoint) remove() error {
+	cp.Close()
+	return os.Remove(cp.path)
+}
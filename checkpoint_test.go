@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	cp, err := openCheckpoint(path, false, "AS_OF_DATE=2024-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := cp.record(ProcLog{SolID: "01", Procedure: "P", StartTime: now, EndTime: now, Status: "SUCCESS", Rows: 3, Bytes: 42}); err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+
+	cp, err = openCheckpoint(path, true, "AS_OF_DATE=2024-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	prev, ok := cp.completed("01", "P")
+	if !ok {
+		t.Fatal("pair recorded before the resume is not completed")
+	}
+	if prev.Rows != 3 || prev.Bytes != 42 {
+		t.Errorf("resumed pair has %d rows and %d bytes, want 3 and 42", prev.Rows, prev.Bytes)
+	}
+	if _, ok := cp.completed("02", "P"); ok {
+		t.Error("pair never recorded is completed")
+	}
+}
+
+func TestCheckpointResumeWithOtherParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	cp, err := openCheckpoint(path, false, "AS_OF_DATE=2024-03-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+	if _, err := openCheckpoint(path, true, "AS_OF_DATE=2024-04-30"); err == nil || !strings.Contains(err.Error(), "2024-03-31") {
+		t.Fatalf("error %v, want one naming the recorded parameters", err)
+	}
+}
+
+func TestCheckpointTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.csv")
+	cp, err := openCheckpoint(path, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("01,P,2024-03-31T10:00:00Z,2024")
+	f.Close()
+
+	cp, err = openCheckpoint(path, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := cp.record(ProcLog{SolID: "02", Procedure: "P", StartTime: now, EndTime: now, Status: "SUCCESS"}); err != nil {
+		t.Fatal(err)
+	}
+	cp.Close()
+
+	cp, err = openCheckpoint(path, true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+	if _, ok := cp.completed("01", "P"); ok {
+		t.Error("torn entry is completed")
+	}
+	if _, ok := cp.completed("02", "P"); !ok {
+		t.Error("entry written after the torn one is not completed")
+	}
+}
+
+func TestSpoolMissing(t *testing.T) {
+	proc := &ProcedureConfig{Name: "P", OutputPath: t.TempDir()}
+	prev := ProcLog{SolID: "01", Procedure: "P", Bytes: 10}
+	if spoolMissing("E", proc, prev) == "" {
+		t.Error("no reason to rerun without a spool file")
+	}
+	if err := os.WriteFile(spoolFilePath(proc, "01"), []byte("short"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if spoolMissing("E", proc, prev) == "" {
+		t.Error("no reason to rerun with a truncated spool file")
+	}
+	if err := os.WriteFile(spoolFilePath(proc, "01"), []byte("0123456789\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if reason := spoolMissing("E", proc, prev); reason != "" {
+		t.Errorf("intact spool reruns: %s", reason)
+	}
+	os.Remove(spoolFilePath(proc, "01"))
+	if reason := spoolMissing("I", proc, prev); reason != "" {
+		t.Errorf("insert mode pair reruns: %s", reason)
+	}
+}
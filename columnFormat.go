@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeLayout writes dates as 2006-01-02 15:04:05, with the fraction of
+// timestamps that have one.
+const defaultTimeLayout = "2006-01-02 15:04:05.999999999"
+
+// readColumnFormat reads the optional type, format, decimals and timezone
+// fields of a template row, and the pad, implied_scale, sign and rounding of
+// numbers.
+func readColumnFormat(col *ColumnConfig, row []string, index map[string]int) error {
+	field := func(name string) string {
+		if i, ok := index[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	col.Type = strings.ToLower(field("type"))
+	col.Format = field("format")
+	col.Timezone = field("timezone")
+	col.Decimals = -1
+
+	switch col.Type {
+	case "", "string", "number", "date", "boolean":
+	default:
+		return fmt.Errorf("invalid type %q: valid values are 'string', 'number', 'date' and 'boolean'", col.Type)
+	}
+	if d := field("decimals"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 || n > 38 {
+			return fmt.Errorf("invalid decimals %q", d)
+		}
+		col.Decimals = n
+	}
+	col.Pad = field("pad")
+	col.Sign = strings.ToLower(field("sign"))
+	col.Rounding = strings.ToLower(field("rounding"))
+	col.ImpliedScale = -1
+	if d := field("implied_scale"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 0 || n > 38 {
+			return fmt.Errorf("invalid implied_scale %q", d)
+		}
+		if col.Decimals >= 0 {
+			return fmt.Errorf("decimals and implied_scale cannot both be set")
+		}
+		col.ImpliedScale = n
+	}
+	if len(col.Pad) > 1 || col.Pad != "" && (col.Pad[0] < ' ' || col.Pad[0] > '~' || strings.ContainsAny(col.Pad, "+-.123456789")) {
+		return fmt.Errorf("invalid pad %q: use a single character other than a sign, decimal point or nonzero digit", col.Pad)
+	}
+	switch col.Sign {
+	case "", "leading", "trailing":
+	default:
+		return fmt.Errorf("invalid sign %q: valid values are 'leading' and 'trailing'", col.Sign)
+	}
+	switch col.Rounding {
+	case "", "half_up", "half_even", "down", "up":
+	default:
+		return fmt.Errorf("invalid rounding %q: valid values are 'half_up', 'half_even', 'down' and 'up'", col.Rounding)
+	}
+	if col.Timezone != "" {
+		loc, err := time.LoadLocation(col.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+		col.location = loc
+	}
+	return nil
+}
+
+// textWidth is the widest text formatValue writes for values of dbCol, or 0
+// when it is not bounded.
+func (col ColumnConfig) textWidth(dbCol dbColumn) int {
+	switch {
+	case dbCol.isTemporal() && col.Type != "string":
+		layout := col.Format
+		if layout == "" {
+			layout = defaultTimeLayout
+		}
+		// A Wednesday in September has the longest day and month names.
+		nanos := 999999999
+		if dbCol.DataType == "DATE" {
+			nanos = 0
+		}
+		return len(time.Date(2006, 9, 27, 23, 59, 59, nanos, time.UTC).Format(layout))
+	case dbCol.DataType == "NUMBER" && dbCol.Precision.Valid && col.ImpliedScale >= 0:
+		// Sign, integer digits and the implied places.
+		return 1 + max(1, int(dbCol.Precision.Int64-dbCol.Scale.Int64)) + col.ImpliedScale
+	case dbCol.DataType == "NUMBER" && dbCol.Precision.Valid && col.Decimals >= 0:
+		// Sign, integer digits and the decimal places.
+		n := 1 + max(1, int(dbCol.Precision.Int64-dbCol.Scale.Int64))
+		if col.Decimals > 0 {
+			n += 1 + col.Decimals
+		}
+		return n
+	}
+	return dbCol.maxTextLength()
+}
+
+// formatValue renders a scanned value as text for the line-based formats.
+func (col ColumnConfig) formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		layout := col.Format
+		if layout == "" {
+			layout = defaultTimeLayout
+		}
+		return col.inZone(v).Format(layout)
+	}
+	return col.formatNumber(v)
+}
+
+// inZone converts a time to the column's timezone, if it has one.
+func (col ColumnConfig) inZone(t time.Time) time.Time {
+	if col.location != nil {
+		return t.In(col.location)
+	}
+	return t
+}
+
+// formatNumber writes a scanned number without exponent, rounded to the
+// column's decimal places when it has them.
+func (col ColumnConfig) formatNumber(v interface{}) string {
+	r, ok := numberRat(v)
+	if !ok {
+		if f, isFloat := v.(float64); isFloat {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return fmt.Sprint(v)
+	}
+	places := col.Decimals
+	if places < 0 {
+		places = decimalPlaces(r)
+	}
+	u := roundScaled(r, places, col.Rounding)
+	if u.Sign() < 0 {
+		return "-" + decimalText(u, places, true)
+	}
+	return decimalText(u, places, true)
+}
+
+// fixedNumber renders a number as a fixed-width field. It is rounded to the
+// implied scale, whose decimal point is left out, or else to the column's
+// decimal places, and signed as the column asks: by default only negatives
+// get a leading minus, while leading and trailing always write + or -. Space
+// padding follows the alignment and keeps the sign next to the digits; any
+// other pad character fills on the left, between a leading sign and the
+// digits. A number wider than the column is an error rather than cut short.
+func (col ColumnConfig) fixedNumber(v interface{}) (string, error) {
+	r, ok := numberRat(v)
+	if !ok {
+		return "", fmt.Errorf("column %s: %v is not a finite number", col.Name, v)
+	}
+	places, point := col.Decimals, true
+	if col.ImpliedScale >= 0 {
+		places, point = col.ImpliedScale, false
+	}
+	if places < 0 {
+		places = decimalPlaces(r)
+	}
+	u := roundScaled(r, places, col.Rounding)
+	digits := decimalText(u, places, point)
+	sign := ""
+	if u.Sign() < 0 {
+		sign = "-"
+	} else if col.Sign == "leading" || col.Sign == "trailing" {
+		sign = "+"
+	}
+	if width := len(sign) + len(digits); width > col.Length {
+		return "", fmt.Errorf("column %s: %s needs %d characters but the column has %d", col.Name, col.formatNumber(v), width, col.Length)
+	}
+
+	pad := col.Pad
+	if pad == "" {
+		pad = " "
+	}
+	fill := strings.Repeat(pad, col.Length-len(sign)-len(digits))
+	leading := sign
+	if col.Sign == "trailing" {
+		leading, digits = "", digits+sign
+	}
+	switch {
+	case pad != " ":
+		return leading + fill + digits, nil
+	case col.Align == "right":
+		return fill + leading + digits, nil
+	}
+	return leading + digits + fill, nil
+}
+
+// parseNumber reads back a number written by formatNumber or, for fixed-width
+// output, by fixedNumber.
+func (col ColumnConfig) parseNumber(s string, fixed bool) (*big.Rat, bool) {
+	s = strings.TrimSpace(s)
+	if !fixed {
+		return new(big.Rat).SetString(s)
+	}
+	neg := false
+	if col.Sign == "trailing" && (strings.HasSuffix(s, "-") || strings.HasSuffix(s, "+")) {
+		neg, s = strings.HasSuffix(s, "-"), s[:len(s)-1]
+	} else if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg, s = strings.HasPrefix(s, "-"), s[1:]
+	}
+	if col.Pad != "" && col.Pad != " " {
+		if s = strings.TrimLeft(s, col.Pad); s == "" {
+			s = "0"
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 {
+		return nil, false
+	}
+	if col.ImpliedScale > 0 {
+		r.Quo(r, new(big.Rat).SetInt(pow10(col.ImpliedScale)))
+	}
+	if neg {
+		r.Neg(r)
+	}
+	return r, true
+}
+
+// numberRat returns a scanned number as a rational, or false for NaN,
+// infinities and values that are not numbers.
+func numberRat(v interface{}) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		// Round the shortest decimal form, not the binary value.
+		return new(big.Rat).SetString(strconv.FormatFloat(v, 'f', -1, 64))
+	case *big.Rat:
+		return v, true
+	}
+	return nil, false
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// roundScaled returns r times 10^places rounded to an integer: half_up (the
+// default) rounds ties away from zero and half_even to the even neighbour,
+// down truncates toward zero and up rounds away from zero.
+func roundScaled(r *big.Rat, places int, mode string) *big.Int {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(places)))
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() == 0 {
+		return q
+	}
+	half := new(big.Int).Lsh(new(big.Int).Abs(rem), 1).Cmp(scaled.Denom())
+	var away bool
+	switch mode {
+	case "up":
+		away = true
+	case "down":
+	case "half_even":
+		away = half > 0 || half == 0 && q.Bit(0) == 1
+	default:
+		away = half >= 0
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(scaled.Sign())))
+	}
+	return q
+}
+
+// decimalText writes the digits of |u| with a decimal point before the last
+// places digits, or without one when point is false.
+func decimalText(u *big.Int, places int, point bool) string {
+	digits := new(big.Int).Abs(u).String()
+	if !point || places == 0 {
+		return digits
+	}
+	if len(digits) <= places {
+		digits = strings.Repeat("0", places-len(digits)+1) + digits
+	}
+	return digits[:len(digits)-places] + "." + digits[len(digits)-places:]
+}
+
+// decimalPlaces is the number of decimal places needed to write r exactly,
+// which is finite for numbers read from decimal text.
+func decimalPlaces(r *big.Rat) int {
+	scaled := new(big.Rat).Set(r)
+	ten := big.NewRat(10, 1)
+	n := 0
+	for !scaled.IsInt() && n < 130 {
+		scaled.Mul(scaled, ten)
+		n++
+	}
+	return n
+}
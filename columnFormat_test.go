@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func rat(s string) *big.Rat {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		panic("invalid test number " + s)
+	}
+	return r
+}
+
+func TestRoundScaled(t *testing.T) {
+	tests := []struct {
+		value  string
+		places int
+		mode   string
+		want   string
+	}{
+		{"2.5", 0, "", "3"},
+		{"-2.5", 0, "half_up", "-3"},
+		{"2.5", 0, "half_even", "2"},
+		{"3.5", 0, "half_even", "4"},
+		{"-2.5", 0, "half_even", "-2"},
+		{"2.9", 0, "down", "2"},
+		{"-2.9", 0, "down", "-2"},
+		{"2.1", 0, "up", "3"},
+		{"-2.1", 0, "up", "-3"},
+		{"2.0", 0, "up", "2"},
+		{"123.455", 2, "half_up", "12346"},
+		{"123.445", 2, "half_even", "12344"},
+		{"0.004", 2, "half_up", "0"},
+		{"-0.005", 2, "half_up", "-1"},
+		{"12", 3, "", "12000"},
+	}
+	for _, tt := range tests {
+		got := roundScaled(rat(tt.value), tt.places, tt.mode)
+		if got.String() != tt.want {
+			t.Errorf("roundScaled(%s, %d, %q) = %s, want %s", tt.value, tt.places, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		decimals int
+		want     string
+	}{
+		{int64(42), -1, "42"},
+		{int64(42), 2, "42.00"},
+		{0.1, -1, "0.1"},
+		{2.675, 2, "2.68"},
+		{rat(".5"), -1, "0.5"},
+		{rat("-.005"), 2, "-0.01"},
+		{rat("-0.004"), 2, "0.00"},
+		{rat("1234.5"), -1, "1234.5"},
+	}
+	for _, tt := range tests {
+		col := ColumnConfig{Decimals: tt.decimals, ImpliedScale: -1}
+		if got := col.formatNumber(tt.value); got != tt.want {
+			t.Errorf("formatNumber(%v, %d) = %q, want %q", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFixedNumber(t *testing.T) {
+	tests := []struct {
+		name  string
+		col   ColumnConfig
+		value interface{}
+		want  string
+	}{
+		{"implied trailing sign", ColumnConfig{Length: 13, Pad: "0", ImpliedScale: 2, Decimals: -1, Sign: "trailing"}, rat("123.45"), "000000012345+"},
+		{"implied negative", ColumnConfig{Length: 13, Pad: "0", ImpliedScale: 2, Decimals: -1, Sign: "trailing"}, rat("-123.455"), "000000012346-"},
+		{"zero padded leading sign", ColumnConfig{Length: 8, Pad: "0", ImpliedScale: -1, Decimals: 2, Sign: "leading"}, int64(-7), "-0007.00"},
+		{"space right aligned", ColumnConfig{Length: 8, Align: "right", ImpliedScale: -1, Decimals: 2}, rat("-3.5"), "   -3.50"},
+		{"space left aligned", ColumnConfig{Length: 6, Align: "left", ImpliedScale: -1, Decimals: 0, Sign: "leading"}, rat("123"), "+123  "},
+		{"truncating", ColumnConfig{Length: 5, Pad: "0", ImpliedScale: 0, Decimals: -1, Rounding: "down"}, 9.99, "00009"},
+		{"half even", ColumnConfig{Length: 4, ImpliedScale: -1, Decimals: 0, Rounding: "half_even", Align: "right"}, rat("2.5"), "   2"},
+		{"exact width", ColumnConfig{Length: 4, ImpliedScale: -1, Decimals: -1}, int64(-123), "-123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.col.fixedNumber(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("fixedNumber(%v) = %q, want %q", tt.value, got, tt.want)
+			}
+			if len(got) != tt.col.Length {
+				t.Fatalf("field is %d characters wide, want %d", len(got), tt.col.Length)
+			}
+		})
+	}
+}
+
+func TestFixedNumberOverflow(t *testing.T) {
+	tests := []struct {
+		col   ColumnConfig
+		value interface{}
+	}{
+		{ColumnConfig{Name: "AMT", Length: 5, ImpliedScale: 2, Decimals: -1}, rat("1234.5")},
+		{ColumnConfig{Name: "AMT", Length: 3, ImpliedScale: -1, Decimals: -1}, int64(-123)},
+		{ColumnConfig{Name: "AMT", Length: 4, ImpliedScale: -1, Decimals: -1, Sign: "trailing"}, int64(1234)},
+		{ColumnConfig{Name: "AMT", Length: 10, ImpliedScale: -1, Decimals: -1}, 1.0 / zero()},
+	}
+	for _, tt := range tests {
+		if got, err := tt.col.fixedNumber(tt.value); err == nil {
+			t.Errorf("fixedNumber(%v) in %d characters = %q, want an error", tt.value, tt.col.Length, got)
+		} else if !strings.Contains(err.Error(), "AMT") {
+			t.Errorf("error %q does not name the column", err)
+		}
+	}
+}
+
+func zero() float64 { return 0 }
+
+func TestParseNumberRoundTrip(t *testing.T) {
+	cols := []ColumnConfig{
+		{Length: 13, Pad: "0", ImpliedScale: 2, Decimals: -1, Sign: "trailing"},
+		{Length: 10, Pad: "0", ImpliedScale: -1, Decimals: 3, Sign: "leading"},
+		{Length: 10, Align: "right", ImpliedScale: -1, Decimals: 2},
+		{Length: 10, Align: "left", ImpliedScale: 1, Decimals: -1},
+		{Length: 10, Pad: "*", ImpliedScale: -1, Decimals: -1},
+	}
+	values := []string{"0", "1", "-1", "123.45", "-0.05", "9999.9"}
+	for i, col := range cols {
+		for _, v := range values {
+			field, err := col.fixedNumber(rat(v))
+			if err != nil {
+				t.Fatalf("column %d: %v", i, err)
+			}
+			got, ok := col.parseNumber(field, true)
+			if !ok {
+				t.Fatalf("column %d: cannot parse %q", i, field)
+			}
+			places := col.Decimals
+			if col.ImpliedScale >= 0 {
+				places = col.ImpliedScale
+			}
+			if places < 0 {
+				places = decimalPlaces(rat(v))
+			}
+			want := new(big.Rat).SetFrac(roundScaled(rat(v), places, col.Rounding), pow10(places))
+			if got.Cmp(want) != 0 {
+				t.Errorf("column %d: %s written as %q reads back as %s", i, v, field, got.FloatString(places))
+			}
+		}
+	}
+}
@@ -2,8 +2,13 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
 )
 
 type MainConfig struct {
@@ -15,17 +20,57 @@ type MainConfig struct {
 	Concurrency int    `json:"concurrency"`
 	LogFilePath string `json:"log_path"`
 	SolFilePath string `json:"sol_list_path"`
+	// ShutdownGraceSeconds is how long in-flight work may run after SIGINT/SIGTERM
+	// before it is cancelled.
+	ShutdownGraceSeconds int `json:"shutdown_grace_seconds"`
+	// MaxOpenFiles limits how many spool files extraction keeps open at once;
+	// it defaults to Concurrency, the number of workers sharing the
+	// connection pool.
+	MaxOpenFiles int `json:"max_open_files"`
 }
 
 type ExtractionConfig struct {
-	PackageName           string   `json:"package_name"`
-	Procedures            []string `json:"procedures"`
-	SpoolOutputPath       string   `json:"spool_output_path"`
-	RunInsertionParallel  bool     `json:"run_insertion_parallel"`
-	RunExtractionParallel bool     `json:"run_extraction_parallel"`
-	TemplatePath          string   `json:"template_path"`
-	Format                string   `json:"format"`
-	Delimiter             string   `json:"delimiter"`
+	PackageName           string            `json:"package_name"`
+	Procedures            []ProcedureConfig `json:"procedures"`
+	SpoolOutputPath       string            `json:"spool_output_path"`
+	RunInsertionParallel  bool              `json:"run_insertion_parallel"`
+	RunExtractionParallel bool              `json:"run_extraction_parallel"`
+	TemplatePath          string            `json:"template_path"`
+	Format                string            `json:"format"`
+	Delimiter             string            `json:"delimiter"`
+	SourceMode            string            `json:"source_mode"`
+	SQLPath               string            `json:"sql_path"`
+	SolFields             []string          `json:"sol_fields"`
+	PartitionKeys         []string          `json:"partition_keys"`
+	BindMode              string            `json:"bind_mode"`
+	Params                map[string]string `json:"params"`
+	Retry                 RetryConfig       `json:"retry"`
+	// TimeoutSeconds limits each procedure call unless the procedure sets its
+	// own; RunTimeoutSeconds is a deadline for the whole run.
+	TimeoutSeconds    int `json:"timeout_seconds"`
+	RunTimeoutSeconds int `json:"run_timeout_seconds"`
+	// ExecutionOrder is sol-major, procedure-major or stages; Stages groups
+	// the per-SOL procedures for the latter.
+	ExecutionOrder string     `json:"execution_order"`
+	Stages         [][]string `json:"stages"`
+	// CaptureDbmsOutput records what insert procedures print with
+	// DBMS_OUTPUT in <package>_insert_output.csv, keeping at most
+	// DbmsOutputMaxBytes (default 1 MiB) per call.
+	CaptureDbmsOutput  bool `json:"capture_dbms_output"`
+	DbmsOutputMaxBytes int  `json:"dbms_output_max_bytes"`
+	// TransactionPerSol runs all of a SOL's procedures in insert mode on one
+	// connection in one transaction, committed only if every one succeeds.
+	// Procedure max_concurrency does not apply to such runs.
+	TransactionPerSol bool `json:"transaction_per_sol"`
+	// QuoteChar, Quoting and Header apply to the csv format: Quoting is
+	// minimal (the default), all or non_numeric, and Header writes the
+	// template column names as the first record of the merged file.
+	QuoteChar string `json:"quote_char"`
+	Quoting   string `json:"quoting"`
+	Header    bool   `json:"header"`
+	// RowGroupSize is the number of rows per row group of parquet files,
+	// 100000 unless set.
+	RowGroupSize int `json:"row_group_size"`
 }
 
 func loadMainConfig(path string) (MainConfig, error) {
@@ -38,6 +83,15 @@ func loadMainConfig(path string) (MainConfig, error) {
 	decoder := json.NewDecoder(file)
 	var cfg MainConfig
 	err = decoder.Decode(&cfg)
+	if cfg.ShutdownGraceSeconds <= 0 {
+		cfg.ShutdownGraceSeconds = 30
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+	if cfg.MaxOpenFiles <= 0 {
+		cfg.MaxOpenFiles = cfg.Concurrency
+	}
 	return cfg, err
 }
 
@@ -51,9 +105,275 @@ func loadExtractionConfig(path string) (ExtractionConfig, error) {
 	decoder := json.NewDecoder(file)
 	var cfg ExtractionConfig
 	err = decoder.Decode(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+	err = cfg.resolveProcedures()
 	return cfg, err
 }
 
+// ProcedureConfig describes a single procedure of a package. Any field left
+// empty falls back to the package-wide setting in ExtractionConfig.
+type ProcedureConfig struct {
+	Name       string `json:"name"`
+	Source     string `json:"source"`
+	SQLFile    string `json:"sql_file"`
+	Template   string `json:"template"`
+	Format     string `json:"format"`
+	Delimiter  string `json:"delimiter"`
+	QuoteChar  string `json:"quote_char"`
+	Quoting    string `json:"quoting"`
+	Header     *bool  `json:"header"`
+	OutputPath string `json:"output_path"`
+	FileName   string `json:"file_name"`
+	// PartitionKeys lists the columns selecting one SOL's rows, each written as
+	// COLUMN or COLUMN=FIELD when the SOL list field has a different name.
+	PartitionKeys []string `json:"partition_keys"`
+	BindMode      string   `json:"bind_mode"`
+	// Global procedures have no partition key and run once per run, either
+	// before or after the per-SOL work as set by GlobalStage.
+	Global      bool   `json:"global"`
+	GlobalStage string `json:"global_stage"`
+	// Params names the run parameters passed to the procedure call after the
+	// partition keys. Filter is an extra condition for generated extraction
+	// queries and may reference run parameters as :NAME.
+	Params         []string `json:"params"`
+	Filter         string   `json:"filter"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+	// MaxConcurrency caps how many SOLs of a heavy procedure run at once;
+	// zero leaves it limited only by the global worker count.
+	MaxConcurrency int `json:"max_concurrency"`
+	// Idempotent marks an insert procedure that may safely run twice for a
+	// SOL, allowing a retry after the connection is lost during the call.
+	Idempotent bool `json:"idempotent"`
+	// DependsOn lists procedures that must succeed for a SOL before this one
+	// runs for it in insert mode; if one does not, this one is SKIPPED.
+	DependsOn []string `json:"depends_on"`
+	// Signature lists the procedure's arguments in call order and replaces
+	// partition keys and params as the call's arguments; IN values come from
+	// the SOL fields and run parameters of the same name. StatusParam,
+	// MessageParam and RowsParam name OUT arguments: a status outside
+	// SuccessValues (default "0") fails the call with the message, and the
+	// rows count is logged.
+	Signature     []ProcParam `json:"signature"`
+	StatusParam   string      `json:"status_param"`
+	SuccessValues []string    `json:"success_values"`
+	MessageParam  string      `json:"message_param"`
+	RowsParam     string      `json:"rows_param"`
+	// ReconcileSumColumns are template columns holding amounts whose totals
+	// are compared with the source in reconciliation mode.
+	ReconcileSumColumns []string `json:"reconcile_sum_columns"`
+	// RowGroupSize overrides the package row group size for parquet output.
+	RowGroupSize int `json:"row_group_size"`
+}
+
+// UnmarshalJSON accepts either a bare procedure name or a full object, so
+// configs written as a plain list of names keep working.
+func (p *ProcedureConfig) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		*p = ProcedureConfig{}
+		return json.Unmarshal(data, &p.Name)
+	}
+	type plain ProcedureConfig
+	var v plain
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = ProcedureConfig(v)
+	return nil
+}
+
+// resolveProcedures fills every procedure's unset fields from the package defaults.
+func (cfg *ExtractionConfig) resolveProcedures() error {
+	switch cfg.SourceMode {
+	case "", "table", "sql":
+	default:
+		return fmt.Errorf("invalid source_mode %q: valid values are 'table' and 'sql'", cfg.SourceMode)
+	}
+	if len(cfg.SolFields) == 0 {
+		cfg.SolFields = []string{"SOL_ID"}
+	}
+	solFields := make(map[string]bool)
+	for i, f := range cfg.SolFields {
+		cfg.SolFields[i] = strings.ToUpper(strings.TrimSpace(f))
+		solFields[cfg.SolFields[i]] = true
+	}
+	if len(cfg.PartitionKeys) == 0 {
+		cfg.PartitionKeys = cfg.SolFields
+	}
+	if cfg.BindMode == "" {
+		cfg.BindMode = "positional"
+	}
+	cfg.Retry.applyDefaults()
+	if cfg.DbmsOutputMaxBytes <= 0 {
+		cfg.DbmsOutputMaxBytes = 1 << 20
+	}
+
+	seen := make(map[string]bool)
+	for i := range cfg.Procedures {
+		p := &cfg.Procedures[i]
+		if p.Name == "" {
+			return fmt.Errorf("procedure at position %d has no name", i+1)
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("procedure %s is listed more than once", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.Source == "" {
+			p.Source = p.Name
+		}
+		if p.Template == "" {
+			p.Template = p.Name + ".csv"
+		}
+		if !filepath.IsAbs(p.Template) {
+			p.Template = filepath.Join(cfg.TemplatePath, p.Template)
+		}
+		if p.SQLFile == "" && cfg.SourceMode == "sql" {
+			p.SQLFile = p.Name + ".sql"
+		}
+		if p.SQLFile != "" && !filepath.IsAbs(p.SQLFile) {
+			p.SQLFile = filepath.Join(cfg.SQLPath, p.SQLFile)
+		}
+		if p.Format == "" {
+			p.Format = cfg.Format
+		}
+		if p.Delimiter == "" {
+			p.Delimiter = cfg.Delimiter
+		}
+		if err := p.resolveFormat(cfg); err != nil {
+			return err
+		}
+		if p.OutputPath == "" {
+			p.OutputPath = cfg.SpoolOutputPath
+		}
+		if p.FileName == "" {
+			p.FileName = p.Name + ".txt"
+			if p.Format == "parquet" {
+				p.FileName = p.Name + ".parquet"
+			}
+		}
+		if p.Global {
+			if len(p.PartitionKeys) > 0 {
+				return fmt.Errorf("global procedure %s cannot have partition keys", p.Name)
+			}
+			if p.GlobalStage == "" {
+				p.GlobalStage = "before"
+			}
+			if p.GlobalStage != "before" && p.GlobalStage != "after" {
+				return fmt.Errorf("invalid global_stage %q for %s: valid values are 'before' and 'after'", p.GlobalStage, p.Name)
+			}
+		} else if len(p.PartitionKeys) == 0 {
+			p.PartitionKeys = cfg.PartitionKeys
+		}
+		for _, k := range p.PartitionKeys {
+			if key := parsePartitionKey(k); !solFields[key.Field] {
+				return fmt.Errorf("partition key %s of %s refers to unknown SOL field %s", k, p.Name, key.Field)
+			}
+		}
+		if p.BindMode == "" {
+			p.BindMode = cfg.BindMode
+		}
+		if p.TimeoutSeconds == 0 {
+			p.TimeoutSeconds = cfg.TimeoutSeconds
+		}
+		if err := p.resolveSignature(); err != nil {
+			return err
+		}
+		if p.MaxConcurrency < 0 {
+			return fmt.Errorf("invalid max_concurrency %d for %s", p.MaxConcurrency, p.Name)
+		}
+		if p.BindMode != "positional" && p.BindMode != "named" {
+			return fmt.Errorf("invalid bind_mode %q for %s: valid values are 'positional' and 'named'", p.BindMode, p.Name)
+		}
+	}
+	if err := cfg.resolveDependencies(); err != nil {
+		return err
+	}
+	return cfg.resolveStages()
+}
+
+// resolveFormat checks the output format and fills the csv and parquet
+// settings.
+func (p *ProcedureConfig) resolveFormat(cfg *ExtractionConfig) error {
+	switch p.Format {
+	case "", "fixed", "delimited", "jsonl":
+		return nil
+	case "parquet":
+		if p.RowGroupSize == 0 {
+			p.RowGroupSize = cfg.RowGroupSize
+		}
+		if p.RowGroupSize == 0 {
+			p.RowGroupSize = 100000
+		}
+		if p.RowGroupSize < 0 {
+			return fmt.Errorf("invalid row_group_size %d for %s", p.RowGroupSize, p.Name)
+		}
+		return nil
+	case "csv":
+	default:
+		return fmt.Errorf("invalid format %q for %s: valid values are 'fixed', 'delimited', 'csv', 'jsonl' and 'parquet'", p.Format, p.Name)
+	}
+	if p.Delimiter == "" {
+		p.Delimiter = ","
+	}
+	if p.QuoteChar == "" {
+		p.QuoteChar = cfg.QuoteChar
+	}
+	if p.QuoteChar == "" {
+		p.QuoteChar = `"`
+	}
+	if p.Quoting == "" {
+		p.Quoting = cfg.Quoting
+	}
+	if p.Quoting == "" {
+		p.Quoting = "minimal"
+	}
+	if p.Header == nil {
+		p.Header = &cfg.Header
+	}
+	if utf8.RuneCountInString(p.QuoteChar) != 1 || strings.ContainsAny(p.QuoteChar, "\r\n") {
+		return fmt.Errorf("quote_char of %s must be a single character", p.Name)
+	}
+	if strings.Contains(p.Delimiter, p.QuoteChar) || strings.ContainsAny(p.Delimiter, "\r\n") {
+		return fmt.Errorf("delimiter of %s must not contain the quote character or line breaks", p.Name)
+	}
+	switch p.Quoting {
+	case "minimal", "all", "non_numeric":
+	default:
+		return fmt.Errorf("invalid quoting %q for %s: valid values are 'minimal', 'all' and 'non_numeric'", p.Quoting, p.Name)
+	}
+	return nil
+}
+
+// hasHeader reports whether the merged file starts with a header record.
+func (p *ProcedureConfig) hasHeader() bool {
+	return p.Format == "csv" && p.Header != nil && *p.Header
+}
+
+// partitionedProcedures returns the procedures that run once per SOL.
+func (cfg *ExtractionConfig) partitionedProcedures() []ProcedureConfig {
+	var procs []ProcedureConfig
+	for _, p := range cfg.Procedures {
+		if !p.Global {
+			procs = append(procs, p)
+		}
+	}
+	return procs
+}
+
+// globalProcedures returns the global procedures scheduled at the given stage.
+func (cfg *ExtractionConfig) globalProcedures(stage string) []ProcedureConfig {
+	var procs []ProcedureConfig
+	for _, p := range cfg.Procedures {
+		if p.Global && p.GlobalStage == stage {
+			procs = append(procs, p)
+		}
+	}
+	return procs
+}
+
 func readSols(path string) ([]string, error) {
 	f, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// csvRecord renders one row in csv format: fields are separated by the
+// delimiter, which may be several characters long, and quoted as the quoting
+// policy asks. Embedded quote characters are doubled as in RFC 4180. NULLs are
+// written as empty, unquoted fields unless every field is quoted.
+func csvRecord(proc *ProcedureConfig, values []string, numeric []bool) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = csvField(proc, v, i < len(numeric) && numeric[i])
+	}
+	return strings.Join(parts, proc.Delimiter)
+}
+
+func csvField(proc *ProcedureConfig, v string, numeric bool) string {
+	quote := proc.QuoteChar
+	var quoted bool
+	switch proc.Quoting {
+	case "all":
+		quoted = true
+	case "non_numeric":
+		quoted = !numeric && v != ""
+	}
+	if !quoted {
+		quoted = strings.Contains(v, proc.Delimiter) || strings.Contains(v, quote) || strings.ContainsAny(v, "\r\n")
+	}
+	if !quoted {
+		return v
+	}
+	return quote + strings.ReplaceAll(v, quote, quote+quote) + quote
+}
+
+// csvHeader renders the template column names as a header record.
+func csvHeader(proc *ProcedureConfig, cols []ColumnConfig) string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.Name
+	}
+	return csvRecord(proc, names, nil)
+}
+
+// recordTerminator ends every record of a format; csv follows RFC 4180.
+func recordTerminator(proc *ProcedureConfig) string {
+	if proc.Format == "csv" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// readCSVRecord reads one csv record, which continues over line breaks while a
+// quoted field is open, and returns it without its terminator.
+func readCSVRecord(r *bufio.Reader, quote string) (string, error) {
+	var rec strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		rec.WriteString(line)
+		if err != nil {
+			if err == io.EOF && rec.Len() > 0 {
+				break
+			}
+			return "", err
+		}
+		// Quotes come in pairs once every quoted field is closed, since
+		// embedded quotes are doubled.
+		if strings.Count(rec.String(), quote)%2 == 0 {
+			break
+		}
+	}
+	s := strings.TrimSuffix(rec.String(), "\n")
+	return strings.TrimSuffix(s, "\r"), nil
+}
+
+// splitCSVRecord splits a csv record into its unquoted field values.
+func splitCSVRecord(rec, delim, quote string) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes := false
+	for i := 0; i < len(rec); {
+		switch {
+		case strings.HasPrefix(rec[i:], quote):
+			if inQuotes && strings.HasPrefix(rec[i+len(quote):], quote) {
+				field.WriteString(quote)
+				i += 2 * len(quote)
+				continue
+			}
+			inQuotes = !inQuotes
+			i += len(quote)
+		case !inQuotes && strings.HasPrefix(rec[i:], delim):
+			fields = append(fields, field.String())
+			field.Reset()
+			i += len(delim)
+		default:
+			field.WriteByte(rec[i])
+			i++
+		}
+	}
+	return append(fields, field.String())
+}
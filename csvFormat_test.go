@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVField(t *testing.T) {
+	tests := []struct {
+		quoting string
+		value   string
+		numeric bool
+		want    string
+	}{
+		{"minimal", "plain", false, "plain"},
+		{"minimal", "a,b", false, `"a,b"`},
+		{"minimal", `say "hi"`, false, `"say ""hi"""`},
+		{"minimal", "two\nlines", false, "\"two\nlines\""},
+		{"minimal", "", false, ""},
+		{"all", "42", true, `"42"`},
+		{"all", "", false, `""`},
+		{"non_numeric", "42", true, "42"},
+		{"non_numeric", "text", false, `"text"`},
+		{"non_numeric", "", false, ""},
+		{"non_numeric", "1,5", true, `"1,5"`},
+	}
+	for _, tt := range tests {
+		proc := &ProcedureConfig{Delimiter: ",", QuoteChar: `"`, Quoting: tt.quoting}
+		if got := csvField(proc, tt.value, tt.numeric); got != tt.want {
+			t.Errorf("csvField(%s, %q) = %q, want %q", tt.quoting, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestSplitCSVRecord(t *testing.T) {
+	tests := []struct {
+		rec, delim, quote string
+		want              []string
+	}{
+		{"a,b,c", ",", `"`, []string{"a", "b", "c"}},
+		{`"a,b",c`, ",", `"`, []string{"a,b", "c"}},
+		{`"say ""hi""",x`, ",", `"`, []string{`say "hi"`, "x"}},
+		{",,", ",", `"`, []string{"", "", ""}},
+		{"a||b||'c||d'", "||", "'", []string{"a", "b", "c||d"}},
+		{"\"two\nlines\",end", ",", `"`, []string{"two\nlines", "end"}},
+	}
+	for _, tt := range tests {
+		if got := splitCSVRecord(tt.rec, tt.delim, tt.quote); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCSVRecord(%q) = %q, want %q", tt.rec, got, tt.want)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	proc := &ProcedureConfig{Format: "csv", Delimiter: ";", QuoteChar: `"`, Quoting: "minimal"}
+	rows := [][]string{
+		{"1", "plain", ""},
+		{"2", "semi;colon", `"quoted"`},
+		{"3", "multi\r\nline", "end"},
+	}
+	var b strings.Builder
+	for _, row := range rows {
+		b.WriteString(csvRecord(proc, row, nil) + recordTerminator(proc))
+	}
+	r := bufio.NewReader(strings.NewReader(b.String()))
+	for i, want := range rows {
+		rec, err := readCSVRecord(r, proc.QuoteChar)
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if got := splitCSVRecord(rec, proc.Delimiter, proc.QuoteChar); !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d = %q, want %q", i, got, want)
+		}
+	}
+	if _, err := readCSVRecord(r, proc.QuoteChar); err != io.EOF {
+		t.Errorf("after the last record got %v, want io.EOF", err)
+	}
+}
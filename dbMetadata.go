@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// dbColumn is a column of a table or view as described by ALL_TAB_COLUMNS.
+type dbColumn struct {
+	Name string
+	// DataType is the dictionary name of the type for table columns, but the
+	// driver's name for query result columns: godror reports BINARY_FLOAT and
+	// BINARY_DOUBLE as FLOAT and DOUBLE, and PL/SQL integers as
+	// BINARY_INTEGER. The type checks accept both.
+	DataType   string
+	DataLength int
+	CharLength int
+	Precision  sql.NullInt64
+	Scale      sql.NullInt64
+	UserType   bool
+}
+
+// fetchTableColumns reads the columns of source, written as TABLE or
+// OWNER.TABLE, in column order. An unqualified name is looked up in the
+// session's current schema.
+func fetchTableColumns(ctx context.Context, db *sql.DB, source string) ([]dbColumn, error) {
+	owner, table, qualified := strings.Cut(strings.ToUpper(source), ".")
+	ownerCond := "SYS_CONTEXT('USERENV', 'CURRENT_SCHEMA')"
+	args := []interface{}{owner}
+	if qualified {
+		ownerCond = ":2"
+		args = []interface{}{table, owner}
+	}
+	query := `SELECT COLUMN_NAME, DATA_TYPE, DATA_LENGTH, CHAR_LENGTH, DATA_PRECISION, DATA_SCALE, DATA_TYPE_OWNER
+		FROM ALL_TAB_COLUMNS
+		WHERE TABLE_NAME = :1 AND OWNER = ` + ownerCond + `
+		ORDER BY COLUMN_ID`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading columns of %s failed: %w", source, err)
+	}
+	defer rows.Close()
+
+	var cols []dbColumn
+	for rows.Next() {
+		var c dbColumn
+		var typeOwner sql.NullString
+		if err := rows.Scan(&c.Name, &c.DataType, &c.DataLength, &c.CharLength, &c.Precision, &c.Scale, &typeOwner); err != nil {
+			return nil, err
+		}
+		c.UserType = typeOwner.Valid
+		cols = append(cols, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%s not found or has no columns visible to this user", source)
+	}
+	return cols, nil
+}
+
+// supportedType reports whether values of an Oracle type can be extracted as text.
+func (c dbColumn) supportedType() bool {
+	if c.UserType {
+		return false
+	}
+	for _, prefix := range []string{"VARCHAR2", "NVARCHAR2", "CHAR", "NCHAR", "NUMBER", "FLOAT", "DOUBLE",
+		"BINARY_FLOAT", "BINARY_DOUBLE", "BINARY_INTEGER", "DATE", "TIMESTAMP", "INTERVAL", "CLOB", "NCLOB", "ROWID"} {
+		if strings.HasPrefix(c.DataType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNumeric reports whether the column holds numbers.
+func (c dbColumn) isNumeric() bool {
+	switch c.DataType {
+	case "NUMBER", "FLOAT", "BINARY_FLOAT", "BINARY_DOUBLE", "DOUBLE", "BINARY_INTEGER":
+		return true
+	}
+	return false
+}
+
+// isTemporal reports whether the column holds dates or timestamps.
+func (c dbColumn) isTemporal() bool {
+	return c.DataType == "DATE" || strings.HasPrefix(c.DataType, "TIMESTAMP")
+}
+
+// timeLayout is the layout generated templates give a date or timestamp
+// column: seconds for dates, plus the fractional digits of timestamps.
+func (c dbColumn) timeLayout() string {
+	layout := "2006-01-02 15:04:05"
+	if strings.HasPrefix(c.DataType, "TIMESTAMP") {
+		digits := 6
+		if c.Scale.Valid {
+			digits = int(c.Scale.Int64)
+		}
+		if digits > 0 {
+			layout += "." + strings.Repeat("0", digits)
+		}
+	}
+	return layout
+}
+
+// maxTextLength is the widest text a value of the column can produce, or 0 when
+// it depends on the template format (dates) or is unbounded.
+func (c dbColumn) maxTextLength() int {
+	switch {
+	case strings.HasSuffix(c.DataType, "CHAR2"), strings.HasSuffix(c.DataType, "CHAR"):
+		if c.CharLength > 0 {
+			return c.CharLength
+		}
+		return c.DataLength
+	case c.DataType == "NUMBER" && c.Precision.Valid:
+		// Sign, digits and decimal point.
+		n := int(c.Precision.Int64) + 1
+		if c.Scale.Valid && c.Scale.Int64 > 0 {
+			n++
+		}
+		return n
+	}
+	return 0
+}
+
+// sourceColumns describes the columns a procedure extracts: the result columns
+// of its SQL file, bound with the first SOL, or the columns of its source table.
+func sourceColumns(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, sols []string, queries map[string]string) ([]dbColumn, error) {
+	query, ok := queries[proc.Name]
+	if !ok {
+		return fetchTableColumns(ctx, db, proc.Source)
+	}
+	solID := globalSol
+	if !proc.Global && len(sols) > 0 {
+		solID = sols[0]
+	}
+	args, err := bindArgs(cfg, proc, query, solID)
+	if err != nil {
+		return nil, err
+	}
+	return describeQuery(ctx, db, query, args)
+}
+
+// describeQuery returns the result columns of a query without fetching any rows.
+func describeQuery(ctx context.Context, db *sql.DB, query string, args []interface{}) ([]dbColumn, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM ("+query+") WHERE 1 = 0", args...)
+	if err != nil {
+		return nil, fmt.Errorf("describing query failed: %w", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]dbColumn, len(types))
+	for i, t := range types {
+		cols[i] = dbColumn{Name: t.Name(), DataType: t.DatabaseTypeName()}
+		if n, ok := t.Length(); ok && n < 1<<31 {
+			cols[i].DataLength = int(n)
+			cols[i].CharLength = int(n)
+		}
+		if p, s, ok := t.DecimalSize(); ok && p > 0 {
+			cols[i].Precision = sql.NullInt64{Int64: p, Valid: true}
+			cols[i].Scale = sql.NullInt64{Int64: s, Valid: true}
+		}
+	}
+	return cols, nil
+}
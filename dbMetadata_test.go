@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func TestDBColumnTypeNames(t *testing.T) {
+	tests := []struct {
+		dataType  string
+		supported bool
+		numeric   bool
+	}{
+		// Dictionary names, from ALL_TAB_COLUMNS.
+		{"NUMBER", true, true},
+		{"FLOAT", true, true},
+		{"BINARY_FLOAT", true, true},
+		{"BINARY_DOUBLE", true, true},
+		{"VARCHAR2", true, false},
+		{"TIMESTAMP(6) WITH TIME ZONE", true, false},
+		{"BLOB", false, false},
+		// Driver names, from a described query.
+		{"DOUBLE", true, true},
+		{"BINARY_INTEGER", true, true},
+		{"TIMESTAMP WITH LOCAL TIME ZONE", true, false},
+		{"INTERVAL DAY TO SECOND", true, false},
+		{"RAW", false, false},
+		{"LONG RAW", false, false},
+	}
+	for _, tt := range tests {
+		c := dbColumn{DataType: tt.dataType}
+		if got := c.supportedType(); got != tt.supported {
+			t.Errorf("%s: supportedType() = %v, want %v", tt.dataType, got, tt.supported)
+		}
+		if got := c.isNumeric(); got != tt.numeric {
+			t.Errorf("%s: isNumeric() = %v, want %v", tt.dataType, got, tt.numeric)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+// outputLog is the per-run detail log of DBMS_OUTPUT lines, one row per line
+// keyed by SOL, procedure and attempt.
+type outputLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// openOutputLog creates the detail log, or appends to it when resuming.
+func openOutputLog(path string, resume bool) (*outputLog, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	o := &outputLog{file: file, writer: csv.NewWriter(file)}
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		o.writer.Write([]string{"SOL_ID", "PROCEDURE", "ATTEMPT", "LINE", "TEXT"})
+		o.writer.Flush()
+	}
+	return o, nil
+}
+
+// write records the output of one call.
+func (o *outputLog) write(solID, proc string, attempt int, output string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		o.writer.Write([]string{solID, proc, strconv.Itoa(attempt), strconv.Itoa(i + 1), line})
+	}
+	o.writer.Flush()
+	if err := o.writer.Error(); err != nil {
+		log.Printf("⚠️ Failed to write DBMS_OUTPUT of %s for SOL %s: %v", proc, solID, err)
+	}
+}
+
+func (o *outputLog) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.writer.Flush()
+	return o.file.Close()
+}
+
+// cappedBuffer keeps the first limit bytes written to it and counts the rest.
+type cappedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	dropped int
+}
+
+// Write never fails, so a large output does not abort reading the buffer.
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if room := max(b.limit-b.buf.Len(), 0); room < n {
+		b.dropped += n - room
+		p = p[:room]
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *cappedBuffer) String() string {
+	s := b.buf.String()
+	if b.dropped > 0 {
+		if !strings.HasSuffix(s, "\n") && s != "" {
+			s += "\n"
+		}
+		s += fmt.Sprintf("[%d more bytes of output truncated]\n", b.dropped)
+	}
+	return s
+}
+
+// withDbmsOutput enables DBMS_OUTPUT on conn, runs fn and returns what the
+// session printed, at most maxBytes of it. conn must be a single session. The
+// buffer is read even when fn fails, since the lines printed before an error
+// are usually the ones worth having.
+func withDbmsOutput(ctx context.Context, conn dbConn, maxBytes int, fn func() error) (string, error) {
+	if err := godror.EnableDbmsOutput(ctx, conn); err != nil {
+		return "", err
+	}
+	err := fn()
+
+	readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	out := &cappedBuffer{limit: maxBytes}
+	if rerr := godror.ReadDbmsOutput(readCtx, out, conn); rerr != nil {
+		log.Printf("⚠️ Failed to read DBMS_OUTPUT: %v", rerr)
+	}
+	// Disabling also discards anything left, so the pooled session does not
+	// keep buffering for the next caller.
+	conn.ExecContext(readCtx, "BEGIN DBMS_OUTPUT.DISABLE; END;")
+	return out.String(), err
+}
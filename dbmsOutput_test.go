@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCappedBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+	}{
+		{"fits", 100, []string{"line 1\n", "line 2\n"}, "line 1\nline 2\n"},
+		{"exact", 7, []string{"line 1\n"}, "line 1\n"},
+		{"cut mid line", 10, []string{"line 1\n", "line 2\n"}, "line 1\nlin\n[4 more bytes of output truncated]\n"},
+		{"cut at line end", 7, []string{"line 1\n", "line 2\n"}, "line 1\n[7 more bytes of output truncated]\n"},
+		{"no room", 0, []string{"line 1\n"}, "[7 more bytes of output truncated]\n"},
+	}
+	for _, tt := range tests {
+		b := &cappedBuffer{limit: tt.limit}
+		for _, w := range tt.writes {
+			if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+				t.Errorf("%s: Write(%q) = %d, %v; want every byte accepted", tt.name, w, n, err)
+			}
+		}
+		if got := b.String(); got != tt.want {
+			t.Errorf("%s: String() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOutputLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.csv")
+	o, err := openOutputLog(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.write("01", "P", 1, "first\nsecond, with comma\n")
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	o, err = openOutputLog(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.write("02", "P", 2, "again")
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SOL_ID,PROCEDURE,ATTEMPT,LINE,TEXT\n" +
+		"01,P,1,1,first\n" +
+		"01,P,1,2,\"second, with comma\"\n" +
+		"02,P,2,1,again\n"
+	if string(data) != want {
+		t.Errorf("output log:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveDependencies checks every depends_on entry and reorders the
+// procedures so that each follows its prerequisites, keeping the configured
+// order otherwise. A procedure may only depend on procedures that run in the
+// same pass: per-SOL on per-SOL, global on global of the same stage.
+func (cfg *ExtractionConfig) resolveDependencies() error {
+	byName := make(map[string]*ProcedureConfig)
+	for i := range cfg.Procedures {
+		byName[cfg.Procedures[i].Name] = &cfg.Procedures[i]
+	}
+	for _, p := range cfg.Procedures {
+		for _, dep := range p.DependsOn {
+			d, ok := byName[dep]
+			switch {
+			case !ok:
+				return fmt.Errorf("%s depends on unknown procedure %s", p.Name, dep)
+			case dep == p.Name:
+				return fmt.Errorf("%s depends on itself", p.Name)
+			case d.Global != p.Global || d.GlobalStage != p.GlobalStage:
+				return fmt.Errorf("%s cannot depend on %s: both must be per-SOL or global procedures of the same stage", p.Name, dep)
+			}
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest configured procedure whose
+	// prerequisites are placed.
+	placed := make(map[string]bool)
+	ordered := make([]ProcedureConfig, 0, len(cfg.Procedures))
+	for len(ordered) < len(cfg.Procedures) {
+		progress := false
+		for _, p := range cfg.Procedures {
+			if placed[p.Name] || !allPlaced(p.DependsOn, placed) {
+				continue
+			}
+			placed[p.Name] = true
+			ordered = append(ordered, p)
+			progress = true
+			break
+		}
+		if !progress {
+			var cycle []string
+			for _, p := range cfg.Procedures {
+				if !placed[p.Name] {
+					cycle = append(cycle, p.Name)
+				}
+			}
+			return fmt.Errorf("depends_on has a cycle; unresolved procedures: %s", strings.Join(cycle, ", "))
+		}
+	}
+	cfg.Procedures = ordered
+	return nil
+}
+
+func allPlaced(names []string, placed map[string]bool) bool {
+	for _, n := range names {
+		if !placed[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyGraph maps each procedure to its prerequisites. Dependencies only
+// order insert mode; extractions are independent reads.
+func (cfg *ExtractionConfig) dependencyGraph(mode string) map[string][]string {
+	if mode != "I" {
+		return nil
+	}
+	deps := make(map[string][]string)
+	for _, p := range cfg.Procedures {
+		if len(p.DependsOn) > 0 {
+			deps[p.Name] = p.DependsOn
+		}
+	}
+	return deps
+}
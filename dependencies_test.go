@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	cfg := ExtractionConfig{Procedures: []ProcedureConfig{
+		{Name: "A"},
+		{Name: "B", DependsOn: []string{"A"}},
+	}}
+	want := map[string][]string{"B": {"A"}}
+	if got := cfg.dependencyGraph("I"); !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyGraph(I) = %v, want %v", got, want)
+	}
+	if got := cfg.dependencyGraph("E"); got != nil {
+		t.Errorf("dependencyGraph(E) = %v, want nil", got)
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	procs := func(ps ...ProcedureConfig) ExtractionConfig {
+		return ExtractionConfig{Procedures: ps}
+	}
+	p := func(name string, deps ...string) ProcedureConfig {
+		return ProcedureConfig{Name: name, DependsOn: deps}
+	}
+	tests := []struct {
+		name  string
+		cfg   ExtractionConfig
+		order []string
+		err   string
+	}{
+		{"configured order kept", procs(p("A"), p("B"), p("C")), []string{"A", "B", "C"}, ""},
+		{"prerequisite moved first", procs(p("A", "C"), p("B"), p("C")), []string{"B", "C", "A"}, ""},
+		{"chain", procs(p("A", "B"), p("B", "C"), p("C")), []string{"C", "B", "A"}, ""},
+		{"direct cycle", procs(p("A", "B"), p("B", "A"), p("C")), nil,
+			"depends_on has a cycle; unresolved procedures: A, B"},
+		{"indirect cycle", procs(p("A", "C"), p("B", "A"), p("C", "B"), p("D")), nil,
+			"depends_on has a cycle; unresolved procedures: A, B, C"},
+		{"self dependency", procs(p("A", "A")), nil, "A depends on itself"},
+		{"unknown procedure", procs(p("A", "MISSING")), nil, "A depends on unknown procedure MISSING"},
+		{"mixed passes", procs(ProcedureConfig{Name: "G", Global: true, GlobalStage: "before"}, p("A", "G")), nil,
+			"A cannot depend on G: both must be per-SOL or global procedures of the same stage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.resolveDependencies()
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var order []string
+			for _, p := range cfg.Procedures {
+				order = append(order, p.Name)
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("order %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
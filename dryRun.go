@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// printPlan writes everything a run would execute: each statement with its
+// bind values per SOL, the spool and merged files and the pool sizing. It
+// neither connects to the database nor writes any file.
+func printPlan(w io.Writer, mode string, appCfg *MainConfig, runCfg *ExtractionConfig, sols []string, templates map[string][]ColumnConfig, queries map[string]string, poolSize int) {
+	modeName := map[string]string{"E": "extract", "I": "insert"}[mode]
+	fmt.Fprintf(w, "Execution plan for %s (%s mode, dry run)\n", runCfg.PackageName, modeName)
+	if len(runCfg.Params) > 0 {
+		fmt.Fprintf(w, "Run parameters: %s\n", formatParams(runCfg.Params))
+	}
+	fmt.Fprintf(w, "SOLs: %d | Procedures: %d\n", len(sols), len(runCfg.Procedures))
+	fmt.Fprintf(w, "Workers: %d (one queue of (SOL, procedure) tasks)\n", appCfg.Concurrency)
+	if mode == "E" {
+		fmt.Fprintf(w, "Open spool files: at most %d\n", appCfg.MaxOpenFiles)
+	}
+	fmt.Fprintf(w, "Connection pool: %d max open, %d max idle\n", poolSize, poolSize)
+	fmt.Fprintf(w, "Retry: up to %d attempt(s), backoff %dms to %dms (x%g)\n",
+		runCfg.Retry.MaxAttempts, runCfg.Retry.InitialBackoffMs, runCfg.Retry.MaxBackoffMs, runCfg.Retry.Multiplier)
+	if runCfg.RunTimeoutSeconds > 0 {
+		fmt.Fprintf(w, "Run deadline: %ds\n", runCfg.RunTimeoutSeconds)
+	}
+
+	fmt.Fprintf(w, "Execution order: %s\n", runCfg.ExecutionOrder)
+	if mode == "I" && runCfg.TransactionPerSol {
+		fmt.Fprintln(w, "Transactions: one per SOL, committed only if all its procedures succeed")
+	}
+	if mode == "I" && runCfg.CaptureDbmsOutput {
+		fmt.Fprintf(w, "DBMS_OUTPUT: captured to %s, up to %d bytes per call\n",
+			filepath.Join(appCfg.LogFilePath, runCfg.PackageName+"_insert_output.csv"), runCfg.DbmsOutputMaxBytes)
+	}
+
+	type section struct {
+		title string
+		procs []ProcedureConfig
+		sols  []string
+	}
+	sections := []section{{"Global procedures (before SOL processing)", runCfg.globalProcedures("before"), []string{globalSol}}}
+	stages := runCfg.executionStages()
+	for i, procs := range stages {
+		title := "Per-SOL procedures"
+		if len(stages) > 1 {
+			title = fmt.Sprintf("Stage %d/%d", i+1, len(stages))
+		}
+		sections = append(sections, section{title, procs, sols})
+	}
+	sections = append(sections, section{"Global procedures (after SOL processing)", runCfg.globalProcedures("after"), []string{globalSol}})
+	for _, sec := range sections {
+		if len(sec.procs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n== %s ==\n", sec.title)
+		for _, proc := range sec.procs {
+			printProcPlan(w, mode, runCfg, &proc, sec.sols, templates, queries)
+		}
+	}
+}
+
+func printProcPlan(w io.Writer, mode string, runCfg *ExtractionConfig, proc *ProcedureConfig, sols []string, templates map[string][]ColumnConfig, queries map[string]string) {
+	var stmt string
+	if mode == "E" {
+		stmt = extractQuery(proc, templates[proc.Name], queries)
+		fmt.Fprintf(w, "\n[%s] format %s", proc.Name, proc.Format)
+		switch proc.Format {
+		case "delimited":
+			fmt.Fprintf(w, " (delimiter %q)", proc.Delimiter)
+		case "csv":
+			fmt.Fprintf(w, " (delimiter %q, quote %q, quoting %s, header %t)", proc.Delimiter, proc.QuoteChar, proc.Quoting, proc.hasHeader())
+		case "parquet":
+			fmt.Fprintf(w, " (row groups of %d rows)", proc.RowGroupSize)
+		}
+		fmt.Fprintln(w)
+	} else {
+		stmt = procedureCall(runCfg, proc)
+		fmt.Fprintf(w, "\n[%s]\n", proc.Name)
+	}
+	if len(proc.DependsOn) > 0 && mode == "I" {
+		fmt.Fprintf(w, "  Depends on: %s\n", strings.Join(proc.DependsOn, ", "))
+	}
+	if proc.StatusParam != "" && mode == "I" {
+		fmt.Fprintf(w, "  Success when %s is one of: %s\n", proc.StatusParam, strings.Join(proc.SuccessValues, ", "))
+	}
+	if proc.MaxConcurrency > 0 {
+		fmt.Fprintf(w, "  Max concurrency: %d\n", proc.MaxConcurrency)
+	}
+	if proc.TimeoutSeconds > 0 {
+		fmt.Fprintf(w, "  Timeout: %ds per call\n", proc.TimeoutSeconds)
+	}
+	fmt.Fprintf(w, "  Statement: %s\n", strings.Join(strings.Fields(stmt), " "))
+	if mode == "E" {
+		fmt.Fprintf(w, "  Merged output: %s\n", filepath.Join(proc.OutputPath, proc.FileName))
+	}
+	for _, solID := range sols {
+		var args []interface{}
+		var err error
+		if mode == "E" {
+			args, err = bindArgs(runCfg, proc, stmt, solID)
+		} else {
+			args, _, err = procedureArgs(runCfg, proc, stmt, solID)
+		}
+		binds := describeArgs(args)
+		if err != nil {
+			binds = "ERROR: " + err.Error()
+		}
+		if mode == "E" {
+			fmt.Fprintf(w, "  SOL %s: binds %s -> %s\n", solID, binds, spoolFilePath(proc, solID))
+		} else {
+			fmt.Fprintf(w, "  SOL %s: binds %s\n", solID, binds)
+		}
+	}
+}
+
+// describeArgs renders bind arguments as [v1, v2] or [NAME=v, ...], with OUT
+// arguments shown as NAME=OUT.
+func describeArgs(args []interface{}) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if named, ok := a.(sql.NamedArg); ok {
+			if out, ok := named.Value.(sql.Out); ok && out.In {
+				parts[i] = fmt.Sprintf("%s=IN OUT %s", named.Name, outValue{dest: out.Dest})
+			} else if ok {
+				parts[i] = named.Name + "=OUT"
+			} else {
+				parts[i] = fmt.Sprintf("%s=%v", named.Name, named.Value)
+			}
+		} else {
+			parts[i] = fmt.Sprint(a)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
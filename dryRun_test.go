@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// planConfig is a resolved package with two per-SOL procedures, one of which
+// depends on the other and binds a run parameter, and a global procedure run
+// after them.
+func planConfig(t *testing.T) *ExtractionConfig {
+	t.Helper()
+	cfg := &ExtractionConfig{
+		PackageName:     "PACK",
+		SpoolOutputPath: "out",
+		Format:          "delimited",
+		Delimiter:       "|",
+		ExecutionOrder:  "procedure-major",
+		Params:          map[string]string{"AS_OF_DATE": "2024-03-31"},
+		Procedures: []ProcedureConfig{
+			{Name: "B", DependsOn: []string{"A"}, Params: []string{"AS_OF_DATE"}, Filter: "D = :AS_OF_DATE", BindMode: "named"},
+			{Name: "A", TimeoutSeconds: 60},
+			{Name: "G", Global: true, GlobalStage: "after"},
+		},
+	}
+	if err := cfg.resolveProcedures(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.applyParams(nil); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestPrintPlan(t *testing.T) {
+	appCfg := &MainConfig{Concurrency: 4, MaxOpenFiles: 8}
+	templates := map[string][]ColumnConfig{
+		"A": {{Name: "ID"}, {Name: "NAME"}},
+		"B": {{Name: "ID"}},
+		"G": {{Name: "TOTAL"}},
+	}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{"E", []string{
+			"Execution plan for PACK (extract mode, dry run)\n",
+			"SOLs: 2 | Procedures: 3\n",
+			"Open spool files: at most 8\n",
+			"Execution order: procedure-major\n",
+			"\n== Stage 1/2 ==\n\n[A] format delimited (delimiter \"|\")\n  Timeout: 60s per call\n" +
+				"  Statement: SELECT ID, NAME FROM A WHERE SOL_ID = :1\n  Merged output: out/A.txt\n" +
+				"  SOL 01: binds [01] -> out/A_01.spool\n  SOL 02: binds [02] -> out/A_02.spool\n",
+			"\n== Stage 2/2 ==\n\n[B] format delimited",
+			"  Statement: SELECT ID FROM B WHERE SOL_ID = :SOL_ID AND (D = :AS_OF_DATE)\n",
+			"  SOL 02: binds [SOL_ID=02, AS_OF_DATE=2024-03-31] -> out/B_02.spool\n",
+			"\n== Global procedures (after SOL processing) ==\n\n[G] format delimited",
+			"  SOL GLOBAL: binds [] -> out/G_GLOBAL.spool\n",
+		}},
+		{"I", []string{
+			"Execution plan for PACK (insert mode, dry run)\n",
+			"\n[B]\n  Depends on: A\n  Statement: BEGIN PACK.B(:SOL_ID, :AS_OF_DATE); END;\n",
+			"  SOL 01: binds [SOL_ID=01, AS_OF_DATE=2024-03-31]\n",
+		}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		printPlan(&b, tt.mode, appCfg, planConfig(t), []string{"01", "02"}, templates, nil, 5)
+		plan := b.String()
+		for _, want := range tt.want {
+			if !strings.Contains(plan, want) {
+				t.Errorf("%s mode plan lacks %q:\n%s", tt.mode, want, plan)
+			}
+		}
+		if tt.mode == "I" && strings.Contains(plan, "spool") {
+			t.Errorf("insert mode plan mentions spool files:\n%s", plan)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// generateTemplates writes a template CSV for every procedure from the
+// dictionary metadata of its source, or from the result columns of its SQL
+// file. Existing templates are left alone unless overwrite is set. It returns
+// the number of procedures that could not be generated.
+func generateTemplates(ctx context.Context, w io.Writer, db *sql.DB, cfg *ExtractionConfig, sols []string, queries map[string]string, overwrite bool) int {
+	failed := 0
+	for _, proc := range cfg.Procedures {
+		if _, err := os.Stat(proc.Template); err == nil && !overwrite {
+			fmt.Fprintf(w, "⏭️ %s: %s already exists (use -overwrite to replace it)\n", proc.Name, proc.Template)
+			continue
+		}
+
+		dbCols, err := sourceColumns(ctx, db, cfg, &proc, sols, queries)
+		if err == nil {
+			err = writeTemplate(proc.Template, dbCols)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "❌ %s: %v\n", proc.Name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "📝 %s: wrote %d columns to %s\n", proc.Name, len(dbCols), proc.Template)
+	}
+	return failed
+}
+
+func writeTemplate(path string, cols []dbColumn) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"name", "length", "align", "type", "format", "decimals", "timezone"})
+	for _, c := range cols {
+		align := "left"
+		typ, format, decimals := "string", "", ""
+		switch {
+		case c.isNumeric():
+			align, typ = "right", "number"
+			if c.Scale.Valid && c.Scale.Int64 > 0 {
+				decimals = strconv.FormatInt(c.Scale.Int64, 10)
+			}
+		case c.isTemporal():
+			typ, format = "date", c.timeLayout()
+		}
+		writer.Write([]string{c.Name, strconv.Itoa(c.defaultLength()), align, typ, format, decimals, ""})
+	}
+	writer.Flush()
+	return errors.Join(writer.Error(), f.Close())
+}
+
+// defaultLength is the fixed-width length given to a generated template column.
+func (c dbColumn) defaultLength() int {
+	if n := c.maxTextLength(); n > 0 {
+		return n
+	}
+	switch {
+	case c.isNumeric():
+		// Up to 38 digits plus sign and decimal point.
+		return 40
+	case c.isTemporal():
+		return len(c.timeLayout())
+	case c.DataType == "ROWID":
+		return 18
+	case c.DataType == "CLOB" || c.DataType == "NCLOB":
+		return 4000
+	}
+	return 30
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// jsonValue converts a scanned value to its JSON form: null for NULL, numbers
+// and booleans unquoted and times as ISO-8601, or in the column's format when
+// it declares one. Infinite and NaN floats are written as strings.
+func jsonValue(col ColumnConfig, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v
+	case time.Time:
+		if col.Format != "" {
+			return col.inZone(v).Format(col.Format)
+		}
+		return col.inZone(v).Format(time.RFC3339Nano)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return col.formatNumber(v)
+		}
+	}
+	return json.Number(col.formatNumber(v))
+}
+
+// jsonRecord renders one row as a JSON object keyed by template column name,
+// in template order.
+func jsonRecord(cols []ColumnConfig, values []interface{}) (string, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, col := range cols {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(col.Name)
+		if err != nil {
+			return "", err
+		}
+		val, err := json.Marshal(jsonValue(col, values[i]))
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+// splitJSONRecord returns the values of a jsonl record in template column
+// order as text, with "" for null and missing keys.
+func splitJSONRecord(line string, cols []ColumnConfig) ([]string, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	dec.UseNumber()
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+	fields := make([]string, len(cols))
+	for i, col := range cols {
+		switch v := obj[col.Name].(type) {
+		case nil:
+		case string:
+			fields[i] = v
+		default:
+			fields[i] = fmt.Sprint(v)
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJSONRecord(t *testing.T) {
+	opened := time.Date(2024, 3, 31, 10, 15, 30, 123000000, time.UTC)
+	ist := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		name  string
+		col   ColumnConfig
+		value interface{}
+		want  string
+	}{
+		{"null", ColumnConfig{}, nil, `null`},
+		{"string", ColumnConfig{}, `say "hi"`, `"say \"hi\""`},
+		{"boolean", ColumnConfig{}, true, `true`},
+		{"integer", ColumnConfig{Decimals: -1}, int64(-42), `-42`},
+		{"decimal", ColumnConfig{Decimals: -1}, big.NewRat(-1, 2), `-0.5`},
+		{"large decimal", ColumnConfig{Decimals: -1}, new(big.Rat).SetFrac64(123456789012345678, 1000), `123456789012345.678`},
+		{"rounded", ColumnConfig{Decimals: 2}, big.NewRat(2675, 1000), `2.68`},
+		{"float", ColumnConfig{Decimals: -1}, 0.25, `0.25`},
+		{"infinity", ColumnConfig{Decimals: -1}, math.Inf(1), `"+Inf"`},
+		{"time", ColumnConfig{}, opened, `"2024-03-31T10:15:30.123Z"`},
+		{"time with format", ColumnConfig{Format: "02-01-2006"}, opened, `"31-03-2024"`},
+		{"time in zone", ColumnConfig{location: ist}, opened, `"2024-03-31T15:45:30.123+05:30"`},
+	}
+	for _, tt := range tests {
+		tt.col.Name = "C"
+		got, err := jsonRecord([]ColumnConfig{tt.col}, []interface{}{tt.value})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := `{"C":` + tt.want + `}`; got != want {
+			t.Errorf("%s: jsonRecord(%v) = %s, want %s", tt.name, tt.value, got, want)
+		}
+	}
+}
+
+func TestSplitJSONRecord(t *testing.T) {
+	cols := []ColumnConfig{{Name: "ID"}, {Name: "NAME"}, {Name: "AMOUNT"}, {Name: "ACTIVE"}, {Name: "MISSING"}}
+	got, err := splitJSONRecord(`{"NAME":"a|b","ID":7,"AMOUNT":12345678901234567890.12,"ACTIVE":null}`, cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"7", "a|b", "12345678901234567890.12", "", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitJSONRecord = %q, want %q", got, want)
+	}
+}
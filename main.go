@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,16 +19,43 @@ var (
 	appCfgFile = new(string)
 	runCfgFile = new(string)
 	mode       string
+	resume     bool
+	dryRun     bool
+	overwrite  bool
+	runParams  = paramFlags{}
 )
 
-func init() {
+// paramFlags collects repeated -param NAME=VALUE options.
+type paramFlags map[string]string
+
+func (p paramFlags) String() string { return formatParams(p) }
+
+func (p paramFlags) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", v)
+	}
+	p[strings.TrimSpace(name)] = value
+	return nil
+}
+
+// parseFlags reads and checks the command line. It is called from main rather
+// than init so that the package can be loaded by go test.
+func parseFlags() {
 	flag.StringVar(appCfgFile, "appCfg", "", "Path to the main application configuration file")
 	flag.StringVar(runCfgFile, "runCfg", "", "Path to the extraction configuration file")
-	flag.StringVar(&mode, "mode", "", "Mode of operation: E - Extract, I - Insert")
+	flag.StringVar(&mode, "mode", "", "Mode of operation: E - Extract, I - Insert, V - Validate templates, G - Generate templates, R - Reconcile extracts")
+	flag.BoolVar(&resume, "resume", false, "Resume an interrupted run, skipping (SOL, procedure) pairs already completed")
+	flag.BoolVar(&dryRun, "dryRun", false, "Print the execution plan without touching the database or the filesystem")
+	flag.BoolVar(&overwrite, "overwrite", false, "Replace existing template files in Generate mode")
+	flag.Var(runParams, "param", "Run parameter as NAME=VALUE, overriding the run config (repeatable)")
 	flag.Parse()
 
-	if mode != "E" && mode != "I" {
-		log.Fatal("Invalid mode. Valid values are 'E' for Extract and 'I' for Insert.")
+	if mode != "E" && mode != "I" && mode != "V" && mode != "G" && mode != "R" {
+		log.Fatal("Invalid mode. Valid values are 'E' for Extract, 'I' for Insert, 'V' for Validate, 'G' for Generate and 'R' for Reconcile.")
+	}
+	if dryRun && (mode == "V" || mode == "G" || mode == "R") {
+		log.Fatal("-dryRun applies to Extract and Insert modes only")
 	}
 	if *appCfgFile == "" || *runCfgFile == "" {
 		log.Fatal("Both appCfg and runCfg must be specified")
@@ -40,7 +68,24 @@ func init() {
 	}
 }
 
+// Exit codes of an Extract or Insert run that did not complete. Startup
+// errors, and problems found in Validate, Generate and Reconcile modes, exit
+// with 1 as well.
+const (
+	exitIncomplete = 1
+	// exitCancelled means the run was stopped by a signal or its deadline
+	// before all work had started.
+	exitCancelled = 2
+)
+
 func main() {
+	parseFlags()
+	os.Exit(run())
+}
+
+// run carries out the command and returns the process exit code. It returns
+// rather than exiting so that deferred cleanup runs first.
+func run() int {
 	appCfg, err := loadMainConfig(*appCfgFile)
 	if err != nil {
 		log.Fatalf("Failed to load main config: %v", err)
@@ -49,16 +94,74 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load extraction config: %v", err)
 	}
+	if err := runCfg.applyParams(runParams); err != nil {
+		log.Fatalf("Invalid run parameters: %v", err)
+	}
+	if len(runCfg.Params) > 0 {
+		log.Printf("Run parameters: %s", formatParams(runCfg.Params))
+	}
 
-	// Load templates
+	// Load templates, unless generating them
 	templates := make(map[string][]ColumnConfig)
-	for _, proc := range runCfg.Procedures {
-		tmplPath := filepath.Join(runCfg.TemplatePath, fmt.Sprintf("%s.csv", proc))
-		cols, err := readColumnsFromCSV(tmplPath)
-		if err != nil {
-			log.Fatalf("Failed to read template for %s: %v", proc, err)
+	if mode != "G" {
+		for _, proc := range runCfg.Procedures {
+			cols, err := readColumnsFromCSV(proc.Template)
+			if err != nil {
+				log.Fatalf("Failed to read template for %s: %v", proc.Name, err)
+			}
+			templates[proc.Name] = cols
+		}
+	}
+
+	if mode == "E" || mode == "R" {
+		for _, proc := range runCfg.Procedures {
+			if proc.Format == "" {
+				log.Fatalf("No output format set for %s", proc.Name)
+			}
 		}
-		templates[proc] = cols
+	}
+
+	// Load SQL files for procedures extracted through a query
+	queries := make(map[string]string)
+	if mode == "E" || mode == "V" || mode == "G" || mode == "R" {
+		for _, proc := range runCfg.Procedures {
+			if proc.SQLFile == "" {
+				continue
+			}
+			query, err := readQueryFromFile(&proc)
+			if err != nil {
+				log.Fatalf("Failed to read SQL for %s: %v", proc.Name, err)
+			}
+			queries[proc.Name] = query
+		}
+		if err := runCfg.checkQueryBinds(queries); err != nil {
+			log.Fatalf("Invalid SQL file: %v", err)
+		}
+	}
+
+	sols, err := readSols(appCfg.SolFilePath)
+	if err != nil {
+		log.Fatalf("Failed to read SOL IDs: %v", err)
+	}
+	for _, sol := range sols {
+		if _, err := splitSol(sol, runCfg.SolFields); err != nil {
+			log.Fatalf("Invalid SOL list entry: %v", err)
+		}
+	}
+
+	if (mode == "I" && !runCfg.RunInsertionParallel) || (mode == "E" && !runCfg.RunExtractionParallel) {
+		log.Println("Running procedures sequentially as parallel execution is disabled")
+		appCfg.Concurrency = 1
+	}
+
+	// Every worker holds at most one connection, so the pool is sized to the
+	// worker budget.
+	solProcs := runCfg.partitionedProcedures()
+	poolSize := appCfg.Concurrency
+
+	if dryRun {
+		printPlan(os.Stdout, mode, &appCfg, &runCfg, sols, templates, queries, poolSize)
+		return 0
 	}
 
 	connString := fmt.Sprintf(`user="%s" password="%s" connectString="%s:%d/%s"`,
@@ -70,78 +173,191 @@ func main() {
 	}
 	defer db.Close()
 
-	procCount := len(runCfg.Procedures)
-	db.SetMaxOpenConns(appCfg.Concurrency * procCount)
-	db.SetMaxIdleConns(appCfg.Concurrency * procCount)
+	db.SetMaxOpenConns(poolSize)
+	db.SetMaxIdleConns(poolSize)
 	db.SetConnMaxLifetime(30 * time.Minute)
 
-	sols, err := readSols(appCfg.SolFilePath)
-	if err != nil {
-		log.Fatalf("Failed to read SOL IDs: %v", err)
+	if mode == "E" {
+		if err := checkQueryColumns(context.Background(), db, &runCfg, sols, templates, queries); err != nil {
+			log.Fatalf("Failed to check SQL file columns: %v", err)
+		}
+	}
+
+	if mode == "V" || mode == "G" || mode == "R" {
+		var problems int
+		switch mode {
+		case "V":
+			problems = validateTemplates(context.Background(), os.Stdout, db, &runCfg, sols, templates, queries)
+		case "G":
+			problems = generateTemplates(context.Background(), os.Stdout, db, &runCfg, sols, queries, overwrite)
+		case "R":
+			problems = reconcile(context.Background(), os.Stdout, db, &runCfg, sols, templates, queries,
+				filepath.Join(appCfg.LogFilePath, runCfg.PackageName+"_extract.csv"),
+				filepath.Join(appCfg.LogFilePath, runCfg.PackageName+"_reconcile.csv"),
+				appCfg.Concurrency)
+		}
+		if problems > 0 {
+			return 1
+		}
+		return 0
 	}
 
 	procLogCh := make(chan ProcLog, 1000)
 	var summaryMu sync.Mutex
 	procSummary := make(map[string]ProcSummary)
 
-	if (mode == "I" && !runCfg.RunInsertionParallel) || (mode == "E" && !runCfg.RunExtractionParallel) {
-		log.Println("Running procedures sequentially as parallel execution is disabled")
-		appCfg.Concurrency = 1
-	}
-
-	var LogFile, LogFileSummary string
+	var LogFile, LogFileSummary, CheckpointFile string
 	if mode == "I" {
 		LogFile = runCfg.PackageName + "_insert.csv"
 		LogFileSummary = runCfg.PackageName + "_insert_summary.csv"
+		CheckpointFile = runCfg.PackageName + "_insert.checkpoint"
 	} else if mode == "E" {
 		LogFile = runCfg.PackageName + "_extract.csv"
 		LogFileSummary = runCfg.PackageName + "_extract_summary.csv"
+		CheckpointFile = runCfg.PackageName + "_extract.checkpoint"
 	}
 
-	go writeLog(filepath.Join(appCfg.LogFilePath, LogFile), procLogCh)
+	if mode == "E" && !resume {
+		// The merge takes every spool on disk, so spools left by an earlier
+		// failed or cancelled run must not outlive it; its checkpoint is
+		// truncated when opened below.
+		removeSpoolFiles(runCfg.Procedures)
+	}
+	runParamsText := formatParams(runCfg.Params)
+	cp, err := openCheckpoint(filepath.Join(appCfg.LogFilePath, CheckpointFile), resume, runParamsText)
+	if err != nil {
+		log.Fatalf("Failed to open checkpoint: %v", err)
+	}
+	if resume {
+		log.Printf("⏯️ Resuming run, %d (SOL, procedure) pairs already completed", len(cp.done))
+	}
 
-	sem := make(chan struct{}, appCfg.Concurrency)
-	var wg sync.WaitGroup
-	ctx := context.Background()
-	totalSols := len(sols)
-	overallStart := time.Now()
-	var mu sync.Mutex
-	completed := 0
+	var outLog *outputLog
+	if mode == "I" && runCfg.CaptureDbmsOutput {
+		outLog, err = openOutputLog(filepath.Join(appCfg.LogFilePath, runCfg.PackageName+"_insert_output.csv"), resume)
+		if err != nil {
+			log.Fatalf("Failed to open DBMS_OUTPUT log: %v", err)
+		}
+		defer outLog.Close()
+	}
 
-	for _, sol := range sols {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(solID string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-			log.Printf("➡️ Starting SOL %s", solID)
-
-			if mode == "E" {
-				runExtractionForSol(ctx, db, solID, &runCfg, templates, procLogCh, &summaryMu, procSummary)
-			} else if mode == "I" {
-				runProceduresForSol(ctx, db, solID, &runCfg, procLogCh, &summaryMu, procSummary)
-			}
+	logDone := make(chan struct{})
+	go func() {
+		writeLog(filepath.Join(appCfg.LogFilePath, LogFile), procLogCh, runParamsText, resume)
+		close(logDone)
+	}()
+
+	dispatchCtx, ctx := handleShutdown(time.Duration(appCfg.ShutdownGraceSeconds) * time.Second)
+	if runCfg.RunTimeoutSeconds > 0 {
+		deadline := time.Now().Add(time.Duration(runCfg.RunTimeoutSeconds) * time.Second)
+		var cancelDispatch, cancelWork context.CancelFunc
+		dispatchCtx, cancelDispatch = context.WithDeadline(dispatchCtx, deadline)
+		defer cancelDispatch()
+		ctx, cancelWork = context.WithDeadline(ctx, deadline)
+		defer cancelWork()
+		log.Printf("⏱️ Run deadline set to %s", deadline.Format("02-01-2006 15:04:05"))
+	}
+	if len(solProcs) == 0 {
+		sols = nil
+	}
+	beforeProcs := runCfg.globalProcedures("before")
+	afterProcs := runCfg.globalProcedures("after")
+	stages := runCfg.executionStages()
 
-			mu.Lock()
-			completed++
-			if completed%100 == 0 || completed == totalSols {
-				elapsed := time.Since(overallStart)
-				estimatedTotal := time.Duration(float64(elapsed) / float64(completed) * float64(totalSols))
-				eta := estimatedTotal - elapsed
-				log.Printf("✅ Progress: %d/%d (%.2f%%) | Elapsed: %s | ETA: %s",
-					completed, totalSols, float64(completed)*100/float64(totalSols),
-					elapsed.Round(time.Second), eta.Round(time.Second))
+	overallStart := time.Now()
+	runner := &taskRunner{
+		db:        db,
+		cfg:       &runCfg,
+		templates: templates,
+		queries:   queries,
+		logCh:     procLogCh,
+		mu:        &summaryMu,
+		summary:   procSummary,
+		cp:        cp,
+		files:     make(chan struct{}, appCfg.MaxOpenFiles),
+		output:    outLog,
+		total:     len(beforeProcs) + len(sols)*len(solProcs) + len(afterProcs),
+		start:     overallStart,
+	}
+	deps := runCfg.dependencyGraph(mode)
+	results := make(map[string]string)
+	mergeFailed := false
+	// runTasks runs one batch to completion. In extract mode the batch's
+	// procedures are merged right away, so a procedure's output is complete
+	// before the next stage starts; procedures that did not succeed for every
+	// SOL, whether they failed or were cut short by a shutdown, are not merged
+	// and are left for -resume.
+	runTasks := func(tasks []task, procs []ProcedureConfig) {
+		newScheduler(tasks, deps, results).run(dispatchCtx, appCfg.Concurrency, func(t task) string {
+			return runner.runTask(ctx, t)
+		})
+		if mode != "E" {
+			return
+		}
+		markUnfinished(procSummary, procs, len(sols))
+		var finished []ProcedureConfig
+		for _, proc := range procs {
+			if procComplete(procSummary, proc, len(sols)) {
+				finished = append(finished, proc)
+			} else {
+				log.Printf("⚠️ Not merging %s: it did not succeed for every SOL", proc.Name)
 			}
-			mu.Unlock()
-		}(sol)
+		}
+		if err := mergeFiles(finished, templates); err != nil {
+			log.Printf("⚠️ Merge failed: %v", err)
+			mergeFailed = true
+		}
 	}
 
-	wg.Wait()
+	if len(beforeProcs) > 0 {
+		log.Printf("🌐 Running %d global procedure(s) before SOL processing", len(beforeProcs))
+		runTasks(globalTasks(beforeProcs), beforeProcs)
+	}
+	for i, procs := range stages {
+		if dispatchCtx.Err() != nil {
+			break
+		}
+		tasks := solTasks(sols, procs)
+		if mode == "I" && runCfg.TransactionPerSol {
+			tasks = transactionTasks(sols, procs)
+		}
+		if len(stages) > 1 {
+			names := make([]string, len(procs))
+			for j, p := range procs {
+				names[j] = p.Name
+			}
+			log.Printf("🧱 Stage %d/%d: %s", i+1, len(stages), strings.Join(names, ", "))
+		}
+		log.Printf("➡️ Scheduling %d task(s) for %d SOL(s) on %d worker(s)", len(tasks), len(sols), appCfg.Concurrency)
+		runTasks(tasks, procs)
+	}
+	if len(afterProcs) > 0 && dispatchCtx.Err() == nil {
+		log.Printf("🌐 Running %d global procedure(s) after SOL processing", len(afterProcs))
+		runTasks(globalTasks(afterProcs), afterProcs)
+	}
 	close(procLogCh)
+	<-logDone
 
-	writeSummary(filepath.Join(appCfg.LogFilePath, LogFileSummary), procSummary)
-	if mode == "E" {
-		mergeFiles(&runCfg)
+	markUnfinished(procSummary, runCfg.Procedures, len(sols))
+	writeSummary(filepath.Join(appCfg.LogFilePath, LogFileSummary), procSummary, runParamsText)
+	// Spool files and the checkpoint are kept after a failure so that -resume
+	// can rebuild the merged output once the failed pairs succeed.
+	succeeded := allSucceeded(procSummary) && !mergeFailed
+	if succeeded {
+		if mode == "E" {
+			removeSpoolFiles(runCfg.Procedures)
+		}
+		cp.remove()
+	} else {
+		cp.Close()
+		log.Printf("⚠️ Run incomplete; rerun with -resume to retry failed or cancelled work")
+	}
+	log.Printf("🎯 All done! Processed %d SOLs in %s", len(sols), time.Since(overallStart).Round(time.Second))
+	switch {
+	case succeeded:
+		return 0
+	case dispatchCtx.Err() != nil:
+		return exitCancelled
 	}
-	log.Printf("🎯 All done! Processed %d SOLs in %s", totalSols, time.Since(overallStart).Round(time.Second))
+	return exitIncomplete
 }
@@ -0,0 +1,99 @@
+package main
+
+import "fmt"
+
+// resolveStages validates execution_order and stages. Stages may only name
+// per-SOL procedures, each exactly once, and a procedure may not depend on
+// one in a later stage.
+func (cfg *ExtractionConfig) resolveStages() error {
+	if cfg.ExecutionOrder == "" {
+		cfg.ExecutionOrder = "sol-major"
+	}
+	if cfg.TransactionPerSol && cfg.ExecutionOrder != "sol-major" {
+		return fmt.Errorf("transaction_per_sol requires execution_order 'sol-major'")
+	}
+	switch cfg.ExecutionOrder {
+	case "sol-major", "procedure-major":
+		if len(cfg.Stages) > 0 {
+			return fmt.Errorf("stages are only used with execution_order 'stages'")
+		}
+		return nil
+	case "stages":
+	default:
+		return fmt.Errorf("invalid execution_order %q: valid values are 'sol-major', 'procedure-major' and 'stages'", cfg.ExecutionOrder)
+	}
+
+	stageOf := make(map[string]int)
+	for i, stage := range cfg.Stages {
+		if len(stage) == 0 {
+			return fmt.Errorf("stage %d is empty", i+1)
+		}
+		for _, name := range stage {
+			if _, dup := stageOf[name]; dup {
+				return fmt.Errorf("procedure %s is listed in more than one stage", name)
+			}
+			stageOf[name] = i
+		}
+	}
+	for _, p := range cfg.Procedures {
+		stage, listed := stageOf[p.Name]
+		delete(stageOf, p.Name)
+		if p.Global {
+			if listed {
+				return fmt.Errorf("global procedure %s cannot be part of a stage; use global_stage", p.Name)
+			}
+			continue
+		}
+		if !listed {
+			return fmt.Errorf("procedure %s is not assigned to a stage", p.Name)
+		}
+		for _, dep := range p.DependsOn {
+			if depStage := cfg.stageIndex(dep); depStage > stage {
+				return fmt.Errorf("%s depends on %s, which runs in a later stage", p.Name, dep)
+			}
+		}
+	}
+	for name := range stageOf {
+		return fmt.Errorf("stage lists unknown procedure %s", name)
+	}
+	return nil
+}
+
+func (cfg *ExtractionConfig) stageIndex(name string) int {
+	for i, stage := range cfg.Stages {
+		for _, n := range stage {
+			if n == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// executionStages splits the per-SOL procedures into stages that run one after
+// another, each across all SOLs. sol-major is a single stage in which a SOL's
+// procedures are queued together; procedure-major makes every procedure its
+// own stage, so each finishes for all SOLs before the next starts. Within a
+// stage, procedures keep their dependency order.
+func (cfg *ExtractionConfig) executionStages() [][]ProcedureConfig {
+	procs := cfg.partitionedProcedures()
+	if len(procs) == 0 {
+		return nil
+	}
+	switch cfg.ExecutionOrder {
+	case "procedure-major":
+		stages := make([][]ProcedureConfig, len(procs))
+		for i := range procs {
+			stages[i] = procs[i : i+1]
+		}
+		return stages
+	case "stages":
+		stages := make([][]ProcedureConfig, len(cfg.Stages))
+		for _, p := range procs {
+			i := cfg.stageIndex(p.Name)
+			stages[i] = append(stages[i], p)
+		}
+		return stages
+	}
+	return [][]ProcedureConfig{procs}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveStages(t *testing.T) {
+	procs := []ProcedureConfig{
+		{Name: "A"},
+		{Name: "B", DependsOn: []string{"A"}},
+		{Name: "C"},
+		{Name: "G", Global: true, GlobalStage: "before"},
+	}
+	tests := []struct {
+		name   string
+		order  string
+		stages [][]string
+		tx     bool
+		err    string
+	}{
+		{"default", "", nil, false, ""},
+		{"procedure-major", "procedure-major", nil, false, ""},
+		{"stages", "stages", [][]string{{"A", "C"}, {"B"}}, false, ""},
+		{"unknown order", "random", nil, false, `invalid execution_order "random"`},
+		{"stages without stages order", "sol-major", [][]string{{"A", "B", "C"}}, false, "only used with execution_order 'stages'"},
+		{"transaction needs sol-major", "procedure-major", nil, true, "transaction_per_sol requires"},
+		{"empty stage", "stages", [][]string{{"A", "B", "C"}, {}}, false, "stage 2 is empty"},
+		{"listed twice", "stages", [][]string{{"A", "C"}, {"B", "A"}}, false, "A is listed in more than one stage"},
+		{"unassigned", "stages", [][]string{{"A", "B"}}, false, "C is not assigned to a stage"},
+		{"unknown procedure", "stages", [][]string{{"A", "B", "C", "X"}}, false, "unknown procedure X"},
+		{"global in stage", "stages", [][]string{{"A", "B", "C", "G"}}, false, "global procedure G cannot be part of a stage"},
+		{"dependency in later stage", "stages", [][]string{{"B", "C"}, {"A"}}, false, "B depends on A, which runs in a later stage"},
+	}
+	for _, tt := range tests {
+		cfg := ExtractionConfig{Procedures: procs, ExecutionOrder: tt.order, Stages: tt.stages, TransactionPerSol: tt.tx}
+		err := cfg.resolveStages()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestExecutionStages(t *testing.T) {
+	procs := []ProcedureConfig{{Name: "A"}, {Name: "G", Global: true}, {Name: "B"}, {Name: "C"}}
+	tests := []struct {
+		order  string
+		stages [][]string
+		want   [][]string
+	}{
+		{"sol-major", nil, [][]string{{"A", "B", "C"}}},
+		{"procedure-major", nil, [][]string{{"A"}, {"B"}, {"C"}}},
+		{"stages", [][]string{{"C"}, {"B", "A"}}, [][]string{{"C"}, {"A", "B"}}},
+	}
+	for _, tt := range tests {
+		cfg := ExtractionConfig{Procedures: procs, ExecutionOrder: tt.order, Stages: tt.stages}
+		var got [][]string
+		for _, stage := range cfg.executionStages() {
+			var names []string
+			for _, p := range stage {
+				names = append(names, p.Name)
+			}
+			got = append(got, names)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: executionStages() = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parquet physical types, converted types, encodings and page types.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetDecimal         = 5
+	parquetTimestampMicros = 10
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetOptional = 1
+	parquetDataPage = 0
+)
+
+// parquetColumn is one column of a parquet schema. Kind is string, boolean,
+// double, timestamp or decimal; decimals of up to 18 digits are stored as
+// INT64, wider ones as big-endian two's complement byte arrays.
+type parquetColumn struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Precision int    `json:"precision,omitempty"`
+	Scale     int    `json:"scale,omitempty"`
+}
+
+// parquetSchema derives the schema of a procedure's parquet file from its
+// template columns and the kinds and types of the query's columns. Numbers
+// without a usable precision become doubles unless the template gives their
+// decimal places, and dates with a template format are written as strings.
+func parquetSchema(cols []ColumnConfig, types []*sql.ColumnType, kinds []columnKind) []parquetColumn {
+	schema := make([]parquetColumn, len(cols))
+	for i, col := range cols {
+		c := parquetColumn{Name: col.Name, Kind: "string"}
+		switch kinds[i] {
+		case numberKind:
+			c.Kind = "double"
+			p, s, ok := types[i].DecimalSize()
+			if ok && types[i].DatabaseTypeName() == "NUMBER" && p > 0 && s >= 0 && s <= p {
+				c.Kind, c.Precision, c.Scale = "decimal", int(p), int(s)
+			}
+			if col.Decimals >= 0 {
+				// Values are rounded to the template's decimal places.
+				if c.Kind == "decimal" {
+					c.Precision = max(1, min(38, c.Precision-c.Scale+col.Decimals))
+				} else {
+					c.Kind, c.Precision = "decimal", 38
+				}
+				c.Scale = col.Decimals
+			}
+		case boolKind:
+			c.Kind = "boolean"
+		case timeKind:
+			if col.Format == "" {
+				c.Kind = "timestamp"
+			}
+		}
+		schema[i] = c
+	}
+	return schema
+}
+
+// parquetSchemaLine is the first line of a parquet spool, recording the schema
+// the merge step writes.
+func parquetSchemaLine(schema []parquetColumn) (string, error) {
+	b, err := json.Marshal(schema)
+	return string(b), err
+}
+
+func (c parquetColumn) physicalType() int32 {
+	switch {
+	case c.Kind == "boolean":
+		return parquetBoolean
+	case c.Kind == "double":
+		return parquetDouble
+	case c.Kind == "timestamp", c.Kind == "decimal" && c.Precision <= 18:
+		return parquetInt64
+	}
+	return parquetByteArray
+}
+
+// convert turns a value decoded from a spool record into the Go value written
+// for the column: nil, bool, float64, int64 or []byte.
+func (c parquetColumn) convert(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	text := fmt.Sprint(v)
+	switch c.Kind {
+	case "boolean":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q is not a boolean", c.Name, text)
+		}
+		return b, nil
+	case "double":
+		return strconv.ParseFloat(text, 64)
+	case "timestamp":
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		return t.UnixMicro(), nil
+	case "decimal":
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return nil, fmt.Errorf("%s: %q is not a number", c.Name, text)
+		}
+		r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(c.Scale)), nil)))
+		if !r.IsInt() {
+			return nil, fmt.Errorf("%s: %s has more than %d decimal places", c.Name, text, c.Scale)
+		}
+		unscaled := r.Num()
+		if c.Precision <= 18 {
+			if !unscaled.IsInt64() {
+				return nil, fmt.Errorf("%s: %s does not fit DECIMAL(%d,%d)", c.Name, text, c.Precision, c.Scale)
+			}
+			return unscaled.Int64(), nil
+		}
+		return twosComplement(unscaled), nil
+	}
+	return []byte(text), nil
+}
+
+// twosComplement returns the minimal big-endian two's complement form of n.
+func twosComplement(n *big.Int) []byte {
+	if n.Sign() >= 0 {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// -n = ^(n-1): invert the bytes of |n|-1.
+	m := new(big.Int).Sub(new(big.Int).Neg(n), big.NewInt(1))
+	b := m.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	return b
+}
+
+// parquetChunk is the metadata of one written column chunk.
+type parquetChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+}
+
+// parquetWriter writes a parquet file of optional, PLAIN encoded and
+// uncompressed columns, one data page per column chunk, buffering a row group
+// at a time.
+type parquetWriter struct {
+	w            *bufio.Writer
+	offset       int64
+	schema       []parquetColumn
+	rowGroupSize int
+	values       [][]interface{}
+	groups       []parquetRowGroup
+	rows         int64
+}
+
+func newParquetWriter(w io.Writer, schema []parquetColumn, rowGroupSize int) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w:            bufio.NewWriter(w),
+		schema:       schema,
+		rowGroupSize: rowGroupSize,
+		values:       make([][]interface{}, len(schema)),
+	}
+	return pw, pw.write([]byte("PAR1"))
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+// add appends a row of converted values, flushing a full row group.
+func (pw *parquetWriter) add(row []interface{}) error {
+	for i, v := range row {
+		pw.values[i] = append(pw.values[i], v)
+	}
+	if len(pw.values[0]) >= pw.rowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *parquetWriter) flushRowGroup() error {
+	n := len(pw.values[0])
+	if n == 0 {
+		return nil
+	}
+	group := parquetRowGroup{rows: int64(n)}
+	for i, col := range pw.schema {
+		page := encodeParquetPage(col, pw.values[i])
+		var h thriftWriter
+		h.begin(0)
+		h.i32(1, parquetDataPage)
+		h.i32(2, int32(len(page)))
+		h.i32(3, int32(len(page)))
+		h.begin(5)
+		h.i32(1, int32(n))
+		h.i32(2, parquetPlain)
+		h.i32(3, parquetRLE)
+		h.i32(4, parquetRLE)
+		h.end()
+		h.end()
+
+		chunk := parquetChunk{offset: pw.offset, numValues: int64(n)}
+		if err := pw.write(h.buf.Bytes()); err != nil {
+			return err
+		}
+		if err := pw.write(page); err != nil {
+			return err
+		}
+		chunk.size = pw.offset - chunk.offset
+		group.chunks = append(group.chunks, chunk)
+		pw.values[i] = pw.values[i][:0]
+	}
+	pw.groups = append(pw.groups, group)
+	pw.rows += group.rows
+	return nil
+}
+
+// encodeParquetPage builds a data page body: the definition levels, bit-packed
+// with a 4-byte length prefix, followed by the PLAIN values of non-null rows.
+func encodeParquetPage(col parquetColumn, values []interface{}) []byte {
+	levels := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v != nil {
+			levels[i/8] |= 1 << (i % 8)
+		}
+	}
+	rle := binary.AppendUvarint(nil, uint64(len(levels))<<1|1)
+	rle = append(rle, levels...)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(rle)))
+	page = append(page, rle...)
+
+	var bits []byte
+	nonNull := 0
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			continue
+		case bool:
+			if nonNull%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[nonNull/8] |= 1 << (nonNull % 8)
+			}
+		case int64:
+			page = binary.LittleEndian.AppendUint64(page, uint64(v))
+		case float64:
+			page = binary.LittleEndian.AppendUint64(page, math.Float64bits(v))
+		case []byte:
+			page = binary.LittleEndian.AppendUint32(page, uint32(len(v)))
+			page = append(page, v...)
+		}
+		nonNull++
+	}
+	return append(page, bits...)
+}
+
+// Close writes the last row group and the footer.
+func (pw *parquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	var t thriftWriter
+	t.begin(0)
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(pw.schema)+1)
+	t.beginElem()
+	t.str(4, "schema")
+	t.i32(5, int32(len(pw.schema)))
+	t.end()
+	for _, col := range pw.schema {
+		t.beginElem()
+		t.i32(1, col.physicalType())
+		t.i32(3, parquetOptional)
+		t.str(4, col.Name)
+		switch col.Kind {
+		case "string":
+			t.i32(6, parquetUTF8)
+		case "timestamp":
+			t.i32(6, parquetTimestampMicros)
+		case "decimal":
+			t.i32(6, parquetDecimal)
+			t.i32(7, int32(col.Scale))
+			t.i32(8, int32(col.Precision))
+		}
+		t.end()
+	}
+	t.i64(3, pw.rows)
+	t.list(4, thriftStruct, len(pw.groups))
+	for _, g := range pw.groups {
+		t.beginElem()
+		t.list(1, thriftStruct, len(g.chunks))
+		var total int64
+		for i, c := range g.chunks {
+			col := pw.schema[i]
+			t.beginElem()
+			t.i64(2, c.offset)
+			t.begin(3)
+			t.i32(1, col.physicalType())
+			t.listI32(2, []int32{parquetPlain, parquetRLE})
+			t.listStr(3, []string{col.Name})
+			t.i32(4, 0)
+			t.i64(5, c.numValues)
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset)
+			t.end()
+			t.end()
+			total += c.size
+		}
+		t.i64(2, total)
+		t.i64(3, g.rows)
+		t.end()
+	}
+	t.str(6, "extract")
+	t.end()
+
+	footer := t.buf.Bytes()
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	if err := pw.write([]byte("PAR1")); err != nil {
+		return err
+	}
+	return pw.w.Flush()
+}
+
+// mergeParquet combines a procedure's spools, each a schema line followed by
+// jsonl records, into one parquet file. All spools must share the schema;
+// without any, the template columns are written as strings.
+func mergeParquet(proc *ProcedureConfig, files []string, cols []ColumnConfig, out io.Writer) error {
+	var schema []parquetColumn
+	if len(files) > 0 {
+		f, err := os.Open(files[0])
+		if err != nil {
+			return err
+		}
+		schema, err = readParquetSchema(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", files[0], err)
+		}
+	} else {
+		for _, col := range cols {
+			schema = append(schema, parquetColumn{Name: col.Name, Kind: "string"})
+		}
+	}
+
+	pw, err := newParquetWriter(out, schema, proc.RowGroupSize)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if err := appendParquetSpool(pw, file); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return pw.Close()
+}
+
+func readParquetSchema(r *bufio.Reader) ([]parquetColumn, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading schema line: %w", err)
+	}
+	var schema []parquetColumn
+	if err := json.Unmarshal([]byte(line), &schema); err != nil {
+		return nil, fmt.Errorf("invalid schema line: %w", err)
+	}
+	return schema, nil
+}
+
+func appendParquetSpool(pw *parquetWriter, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReaderSize(f, 64*1024)
+	schema, err := readParquetSchema(r)
+	if err != nil {
+		return err
+	}
+	if !slices.Equal(schema, pw.schema) {
+		return errors.New("schema differs from the other spools of the procedure")
+	}
+	row := make([]interface{}, len(schema))
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && line == "" {
+			return nil
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.UseNumber()
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err != nil {
+			return err
+		}
+		for i, col := range schema {
+			if row[i], err = col.convert(obj[col.Name]); err != nil {
+				return err
+			}
+		}
+		if err := pw.add(row); err != nil {
+			return err
+		}
+	}
+}
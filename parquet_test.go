@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The golden files in testdata were checked by reading them with
+// github.com/parquet-go/parquet-go v0.25.1; its dump of each file is kept
+// next to it as a .txt file. After a deliberate change to the writer, rerun
+// with -update and check the new files the same way.
+var update = flag.Bool("update", false, "rewrite the parquet golden files in testdata")
+
+var typedSchema = []parquetColumn{
+	{Name: "ID", Kind: "decimal", Precision: 10},
+	{Name: "AMOUNT", Kind: "decimal", Precision: 30, Scale: 4},
+	{Name: "NAME", Kind: "string"},
+	{Name: "ACTIVE", Kind: "boolean"},
+	{Name: "OPENED", Kind: "timestamp"},
+	{Name: "RATE", Kind: "double"},
+}
+
+// writeParquetSpool writes a parquet spool: the schema line and the records.
+func writeParquetSpool(t *testing.T, dir, name string, schema []parquetColumn, records ...string) string {
+	t.Helper()
+	line, err := parquetSchemaLine(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	data := line + "\n" + strings.Join(records, "")
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (%d bytes, want %d); rerun with -update if the change is intended",
+			path, len(got), len(want))
+	}
+}
+
+func TestMergeParquetGolden(t *testing.T) {
+	dir := t.TempDir()
+	typed := []string{
+		writeParquetSpool(t, dir, "P_01.spool", typedSchema,
+			`{"ID":1,"AMOUNT":12345678901234567890.1234,"NAME":"first","ACTIVE":true,"OPENED":"2024-03-31T10:15:30.123456Z","RATE":1.5}`+"\n",
+			`{"ID":2,"AMOUNT":-0.0001,"NAME":"","ACTIVE":false,"OPENED":"1969-12-31T23:59:59Z","RATE":-0.25}`+"\n",
+			`{"ID":null,"AMOUNT":null,"NAME":null,"ACTIVE":null,"OPENED":null,"RATE":null}`+"\n"),
+		writeParquetSpool(t, dir, "P_02.spool", typedSchema,
+			`{"ID":9999999999,"AMOUNT":-99999999999999999999999999.9999,"NAME":"naïve","ACTIVE":true,"OPENED":"2024-02-29T00:00:00+05:30","RATE":1e300}`+"\n",
+			`{"ID":-42,"AMOUNT":128,"NAME":"last","ACTIVE":null,"OPENED":"2000-01-01T00:00:00Z","RATE":0}`),
+	}
+	empty := []string{writeParquetSpool(t, dir, "E_01.spool", typedSchema)}
+	cols := []ColumnConfig{{Name: "PROCNAME"}, {Name: "EXECDATE"}}
+
+	tests := []struct {
+		name   string
+		files  []string
+		groups int
+	}{
+		{"typed", typed, 2},
+		{"empty_spool", empty, 100000},
+		{"no_spools", nil, 100000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			proc := &ProcedureConfig{Name: "P", RowGroupSize: tt.groups}
+			if err := mergeParquet(proc, tt.files, cols, &out); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, tt.name+".parquet", out.Bytes())
+		})
+	}
+}
+
+func TestMergeParquetErrors(t *testing.T) {
+	dir := t.TempDir()
+	other := append([]parquetColumn(nil), typedSchema...)
+	other[5].Kind = "string"
+	tests := []struct {
+		name  string
+		files []string
+		err   string
+	}{
+		{"schema mismatch", []string{
+			writeParquetSpool(t, dir, "A_01.spool", typedSchema),
+			writeParquetSpool(t, dir, "A_02.spool", other),
+		}, "schema differs"},
+		{"inexact decimal", []string{
+			writeParquetSpool(t, dir, "B_01.spool", typedSchema, `{"ID":1.5}`+"\n"),
+		}, "more than 0 decimal places"},
+		{"decimal overflow", []string{
+			writeParquetSpool(t, dir, "C_01.spool", typedSchema, `{"ID":99999999999999999999}`+"\n"),
+		}, "does not fit DECIMAL(10,0)"},
+		{"not a boolean", []string{
+			writeParquetSpool(t, dir, "D_01.spool", typedSchema, `{"ACTIVE":"Y"}`+"\n"),
+		}, "is not a boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := mergeParquet(&ProcedureConfig{Name: "P", RowGroupSize: 10}, tt.files, nil, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestTwosComplement(t *testing.T) {
+	tests := []struct {
+		n    string
+		want []byte
+	}{
+		{"0", []byte{0x00}},
+		{"1", []byte{0x01}},
+		{"127", []byte{0x7f}},
+		{"128", []byte{0x00, 0x80}},
+		{"-1", []byte{0xff}},
+		{"-128", []byte{0x80}},
+		{"-129", []byte{0xff, 0x7f}},
+		{"65535", []byte{0x00, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		n, _ := new(big.Int).SetString(tt.n, 10)
+		if got := twosComplement(n); !bytes.Equal(got, tt.want) {
+			t.Errorf("twosComplement(%s) = % x, want % x", tt.n, got, tt.want)
+		}
+	}
+}
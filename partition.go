@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// globalSol is the SOL ID recorded for procedures that run once per run.
+const globalSol = "GLOBAL"
+
+// partitionKey maps a column of a procedure's source onto a field of the SOL list.
+type partitionKey struct {
+	Column string
+	Field  string
+}
+
+// parsePartitionKey reads a key written as "COLUMN" or "COLUMN=FIELD". A bare
+// column takes the SOL list field of the same name.
+func parsePartitionKey(s string) partitionKey {
+	column, field, found := strings.Cut(s, "=")
+	column = strings.ToUpper(strings.TrimSpace(column))
+	if !found {
+		return partitionKey{Column: column, Field: column}
+	}
+	return partitionKey{Column: column, Field: strings.ToUpper(strings.TrimSpace(field))}
+}
+
+// splitSol breaks a SOL list line such as "01,0001" into its named fields.
+func splitSol(solID string, fields []string) (map[string]string, error) {
+	parts := strings.Split(solID, ",")
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("SOL %q has %d fields but sol_fields defines %d (%s)",
+			solID, len(parts), len(fields), strings.Join(fields, ", "))
+	}
+	values := make(map[string]string, len(fields))
+	for i, f := range fields {
+		values[f] = strings.TrimSpace(parts[i])
+	}
+	return values, nil
+}
+
+// solFileName turns a SOL list line into something safe to embed in a file name.
+func solFileName(solID string) string {
+	parts := strings.Split(solID, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return strings.Join(parts, "_")
+}
+
+// partitionFilter builds the WHERE condition selecting one partition of a
+// procedure's source table.
+func partitionFilter(proc *ProcedureConfig) string {
+	conds := make([]string, len(proc.PartitionKeys))
+	for i, k := range proc.PartitionKeys {
+		key := parsePartitionKey(k)
+		if proc.BindMode == "named" {
+			conds[i] = fmt.Sprintf("%s = :%s", key.Column, key.Field)
+		} else {
+			conds[i] = fmt.Sprintf("%s = :%d", key.Column, i+1)
+		}
+	}
+	return strings.Join(conds, " AND ")
+}
+
+// partitionValues resolves the SOL list fields available as bind values for
+// one SOL. Global procedures have none.
+func partitionValues(cfg *ExtractionConfig, proc *ProcedureConfig, solID string) (map[string]string, error) {
+	if proc.Global {
+		return map[string]string{}, nil
+	}
+	return splitSol(solID, cfg.SolFields)
+}
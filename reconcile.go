@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// totals are the row count and amount sums of one extract, taken either from
+// the source or from a written file.
+type totals struct {
+	Rows int64
+	Sums []*big.Rat
+}
+
+func newTotals(n int) totals {
+	t := totals{Sums: make([]*big.Rat, n)}
+	for i := range t.Sums {
+		t.Sums[i] = new(big.Rat)
+	}
+	return t
+}
+
+func (t *totals) add(o totals) {
+	t.Rows += o.Rows
+	for i := range t.Sums {
+		t.Sums[i].Add(t.Sums[i], o.Sums[i])
+	}
+}
+
+// reconRecord is one line of the reconciliation report.
+type reconRecord struct {
+	Procedure string
+	SolID     string
+	Measure   string
+	Source    string
+	Extract   string
+	From      string
+	Status    string
+	Details   string
+}
+
+// reconcile compares, for every procedure and SOL, the rows (and configured
+// amount sums) written by the extraction with the same figures taken from the
+// source, then does the same for each merged file as a whole. Per-SOL figures
+// come from the spool file while it still exists and from the extract log
+// otherwise. The report is written to path; the number of mismatches and
+// errors is returned.
+func reconcile(ctx context.Context, w io.Writer, db *sql.DB, cfg *ExtractionConfig, sols []string, templates map[string][]ColumnConfig, queries map[string]string, logPath, path string, concurrency int) int {
+	logged, err := readExtractLog(logPath)
+	if err != nil {
+		log.Printf("⚠️ Extract log unavailable, per-SOL counts will come from spool files only: %v", err)
+	}
+
+	var records []reconRecord
+	for _, proc := range cfg.Procedures {
+		records = append(records, reconcileProcedure(ctx, db, cfg, &proc, sols, templates[proc.Name], queries, logged, concurrency)...)
+	}
+
+	problems := 0
+	for _, r := range records {
+		switch r.Status {
+		case "ERROR":
+			problems++
+			fmt.Fprintf(w, "❌ %s SOL %s %s: %s\n", r.Procedure, r.SolID, r.Measure, r.Details)
+		case "MISMATCH":
+			problems++
+			fmt.Fprintf(w, "❌ %s SOL %s %s: source %s, extract %s (%s)\n", r.Procedure, r.SolID, r.Measure, r.Source, r.Extract, r.From)
+		}
+	}
+	if err := writeReconciliation(path, records); err != nil {
+		log.Printf("Failed to write reconciliation report: %v", err)
+		problems++
+	}
+	fmt.Fprintf(w, "Reconciled %d procedure(s), %d problem(s) found, report written to %s\n", len(cfg.Procedures), problems, path)
+	return problems
+}
+
+func reconcileProcedure(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, sols []string, cols []ColumnConfig, queries map[string]string, logged map[string]int64, concurrency int) []reconRecord {
+	sumIdx := make([]int, len(proc.ReconcileSumColumns))
+	for i, name := range proc.ReconcileSumColumns {
+		sumIdx[i] = -1
+		for j, col := range cols {
+			if strings.EqualFold(col.Name, name) {
+				sumIdx[i] = j
+			}
+		}
+		if sumIdx[i] < 0 {
+			return []reconRecord{{Procedure: proc.Name, Measure: "SUM(" + name + ")", Status: "ERROR",
+				Details: "column not in template"}}
+		}
+	}
+
+	solList := sols
+	if proc.Global {
+		solList = []string{globalSol}
+	}
+
+	type result struct {
+		source, extract totals
+		from            string
+		sourceErr       error
+		extractErr      error
+	}
+	results := make([]result, len(solList))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, solID := range solList {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, solID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			r := &results[i]
+			r.source, r.sourceErr = sourceTotals(ctx, db, cfg, proc, cols, queries, solID)
+			r.extract, r.from, r.extractErr = extractTotals(proc, cols, sumIdx, solID, logged)
+		}(i, solID)
+	}
+	wg.Wait()
+
+	var records []reconRecord
+	sourceAll := newTotals(len(sumIdx))
+	sourceComplete := true
+	for i, solID := range solList {
+		r := results[i]
+		if r.sourceErr != nil {
+			sourceComplete = false
+		} else {
+			sourceAll.add(r.source)
+		}
+		records = append(records, compareTotals(proc, solID, r.source, r.sourceErr, r.extract, r.from, r.extractErr)...)
+	}
+
+	// The merged parquet file is binary and is not read back; its spools
+	// were compared above.
+	merged := filepath.Join(proc.OutputPath, proc.FileName)
+	if _, err := os.Stat(merged); err == nil && proc.Format != "parquet" {
+		var sourceErr error
+		if !sourceComplete {
+			sourceErr = errors.New("source totals incomplete")
+		}
+		ext, err := fileTotals(merged, proc, cols, sumIdx, proc.hasHeader())
+		records = append(records, compareTotals(proc, "TOTAL", sourceAll, sourceErr, ext, "merged", err)...)
+	}
+	return records
+}
+
+// sourceTotals counts, and sums where configured, the rows the extraction
+// query returns for one SOL.
+func sourceTotals(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, cols []ColumnConfig, queries map[string]string, solID string) (totals, error) {
+	query := extractQuery(proc, cols, queries)
+	selects := []string{"COUNT(*)"}
+	for _, name := range proc.ReconcileSumColumns {
+		selects = append(selects, fmt.Sprintf("SUM(%s)", name))
+	}
+	wrapped := fmt.Sprintf("SELECT %s FROM (%s)", strings.Join(selects, ", "), query)
+	args, err := bindArgs(cfg, proc, wrapped, solID)
+	if err != nil {
+		return totals{}, err
+	}
+
+	values := make([]sql.NullString, len(selects))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := db.QueryRowContext(ctx, wrapped, args...).Scan(dest...); err != nil {
+		return totals{}, fmt.Errorf("source count failed: %w", err)
+	}
+
+	t := newTotals(len(proc.ReconcileSumColumns))
+	t.Rows, err = strconv.ParseInt(values[0].String, 10, 64)
+	if err != nil {
+		return totals{}, err
+	}
+	for i, v := range values[1:] {
+		if v.Valid {
+			if _, ok := t.Sums[i].SetString(v.String); !ok {
+				return totals{}, fmt.Errorf("source sum %q is not a number", v.String)
+			}
+		}
+	}
+	return t, nil
+}
+
+// extractTotals measures what the extraction wrote for one SOL, reading the
+// spool file when present and falling back to the row count in the extract log.
+func extractTotals(proc *ProcedureConfig, cols []ColumnConfig, sumIdx []int, solID string, logged map[string]int64) (totals, string, error) {
+	spool := spoolFilePath(proc, solID)
+	if _, err := os.Stat(spool); err == nil {
+		// Parquet spools start with their schema line.
+		t, err := fileTotals(spool, proc, cols, sumIdx, proc.Format == "parquet")
+		return t, "spool", err
+	}
+	if rows, ok := logged[checkpointKey(solID, proc.Name)]; ok {
+		return totals{Rows: rows}, "log", nil
+	}
+	return totals{}, "", errors.New("no spool file or successful log entry")
+}
+
+// fileTotals counts the rows of an extract file and sums the given columns,
+// skipping the header record when the file has one.
+func fileTotals(path string, proc *ProcedureConfig, cols []ColumnConfig, sumIdx []int, header bool) (totals, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return totals{}, err
+	}
+	defer f.Close()
+
+	t := newTotals(len(sumIdx))
+	next := newRecordReader(f, proc, cols)
+	if header {
+		if _, err := next(); err != nil && err != io.EOF {
+			return t, err
+		}
+	}
+	for {
+		fields, err := next()
+		if err == io.EOF {
+			return t, nil
+		}
+		if err != nil {
+			return t, err
+		}
+		t.Rows++
+		for i, idx := range sumIdx {
+			if idx >= len(fields) {
+				return t, fmt.Errorf("record %d has only %d fields", t.Rows, len(fields))
+			}
+			v := strings.TrimSpace(fields[idx])
+			if v == "" {
+				continue
+			}
+			n, ok := cols[idx].parseNumber(v, proc.Format == "fixed")
+			if !ok {
+				return t, fmt.Errorf("record %d: %s value %q is not a number", t.Rows, cols[idx].Name, v)
+			}
+			t.Sums[i].Add(t.Sums[i], n)
+		}
+	}
+}
+
+// recordReader returns the records of an extract file one at a time, split
+// into field values, and io.EOF after the last one.
+type recordReader func() ([]string, error)
+
+func newRecordReader(r io.Reader, proc *ProcedureConfig, cols []ColumnConfig) recordReader {
+	if proc.Format == "csv" {
+		br := bufio.NewReaderSize(r, 64*1024)
+		return func() ([]string, error) {
+			rec, err := readCSVRecord(br, proc.QuoteChar)
+			if err != nil {
+				return nil, err
+			}
+			return splitCSVRecord(rec, proc.Delimiter, proc.QuoteChar), nil
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return func() ([]string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		if proc.Format == "jsonl" || proc.Format == "parquet" {
+			return splitJSONRecord(scanner.Text(), cols)
+		}
+		return parseRow(proc, cols, scanner.Text()), nil
+	}
+}
+
+// parseRow splits a line written by formatRow back into its field values.
+func parseRow(proc *ProcedureConfig, cols []ColumnConfig, line string) []string {
+	switch proc.Format {
+	case "fixed":
+		runes := []rune(line)
+		fields := make([]string, 0, len(cols))
+		pos := 0
+		for _, col := range cols {
+			end := min(pos+col.Length, len(runes))
+			fields = append(fields, string(runes[pos:end]))
+			pos = end
+		}
+		return fields
+	default:
+		return strings.Split(line, proc.Delimiter)
+	}
+}
+
+func compareTotals(proc *ProcedureConfig, solID string, src totals, srcErr error, ext totals, from string, extErr error) []reconRecord {
+	base := reconRecord{Procedure: proc.Name, SolID: solID, From: from}
+	if srcErr != nil || extErr != nil {
+		r := base
+		r.Measure = "ROWS"
+		r.Status = "ERROR"
+		r.Details = errors.Join(srcErr, extErr).Error()
+		return []reconRecord{r}
+	}
+
+	r := base
+	r.Measure = "ROWS"
+	r.Source = strconv.FormatInt(src.Rows, 10)
+	r.Extract = strconv.FormatInt(ext.Rows, 10)
+	r.Status = matchStatus(src.Rows == ext.Rows)
+	records := []reconRecord{r}
+
+	for i, name := range proc.ReconcileSumColumns {
+		r := base
+		r.Measure = "SUM(" + name + ")"
+		r.Source = formatRat(src.Sums[i])
+		if ext.Sums == nil {
+			r.Extract = "-"
+			r.Status = "UNAVAILABLE"
+			r.Details = "spool file already merged"
+		} else {
+			r.Extract = formatRat(ext.Sums[i])
+			r.Status = matchStatus(src.Sums[i].Cmp(ext.Sums[i]) == 0)
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+func matchStatus(ok bool) string {
+	if ok {
+		return "MATCH"
+	}
+	return "MISMATCH"
+}
+
+// formatRat prints an exact decimal without trailing zeros.
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	s := strings.TrimRight(r.FloatString(18), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// readExtractLog returns the row count of the latest successful run of every
+// (SOL, procedure) pair in an extract log.
+func readExtractLog(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		index[h] = i
+	}
+	for _, h := range []string{"SOL_ID", "PROCEDURE", "STATUS", "ROWS"} {
+		if _, ok := index[h]; !ok {
+			return nil, fmt.Errorf("%s has no %s column", path, h)
+		}
+	}
+
+	rows := make(map[string]int64)
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) != len(header) || rec[index["STATUS"]] != "SUCCESS" {
+			continue
+		}
+		n, err := strconv.ParseInt(rec[index["ROWS"]], 10, 64)
+		if err != nil {
+			continue
+		}
+		rows[checkpointKey(rec[index["SOL_ID"]], rec[index["PROCEDURE"]])] = n
+	}
+}
+
+func writeReconciliation(path string, records []reconRecord) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"PROCEDURE", "SOL_ID", "MEASURE", "SOURCE_VALUE", "EXTRACT_VALUE", "EXTRACT_FROM", "STATUS", "DETAILS"})
+	for _, r := range records {
+		details := r.Details
+		if details == "" {
+			details = "-"
+		}
+		writer.Write([]string{r.Procedure, r.SolID, r.Measure, r.Source, r.Extract, r.From, r.Status, details})
+	}
+	writer.Flush()
+	return writer.Error()
+}
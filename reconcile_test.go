@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareTotals(t *testing.T) {
+	proc := &ProcedureConfig{Name: "P", ReconcileSumColumns: []string{"AMOUNT"}}
+	sums := func(rows int64, amount string) totals {
+		r, _ := new(big.Rat).SetString(amount)
+		return totals{Rows: rows, Sums: []*big.Rat{r}}
+	}
+	tests := []struct {
+		name     string
+		src, ext totals
+		srcErr   error
+		extErr   error
+		want     [][3]string // measure, extract value, status
+	}{
+		{"match", sums(3, "10.50"), sums(3, "10.5"), nil, nil,
+			[][3]string{{"ROWS", "3", "MATCH"}, {"SUM(AMOUNT)", "10.5", "MATCH"}}},
+		{"row mismatch", sums(3, "10.5"), sums(2, "10.5"), nil, nil,
+			[][3]string{{"ROWS", "2", "MISMATCH"}, {"SUM(AMOUNT)", "10.5", "MATCH"}}},
+		{"sum mismatch", sums(3, "10.5"), sums(3, "-0.25"), nil, nil,
+			[][3]string{{"ROWS", "3", "MATCH"}, {"SUM(AMOUNT)", "-0.25", "MISMATCH"}}},
+		{"merged spool", sums(3, "10.5"), totals{Rows: 3}, nil, nil,
+			[][3]string{{"ROWS", "3", "MATCH"}, {"SUM(AMOUNT)", "-", "UNAVAILABLE"}}},
+		{"source error", totals{}, sums(3, "10.5"), errors.New("ORA-00942"), nil,
+			[][3]string{{"ROWS", "", "ERROR"}}},
+	}
+	for _, tt := range tests {
+		records := compareTotals(proc, "01", tt.src, tt.srcErr, tt.ext, "spool", tt.extErr)
+		var got [][3]string
+		for _, r := range records {
+			got = append(got, [3]string{r.Measure, r.Extract, r.Status})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: compareTotals = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileTotals(t *testing.T) {
+	cols := []ColumnConfig{
+		{Name: "ID", Length: 3},
+		{Name: "AMOUNT", Length: 8, Pad: "0", ImpliedScale: 2, Sign: "trailing"},
+	}
+	tests := []struct {
+		name string
+		proc ProcedureConfig
+		data string
+		rows int64
+		sum  string
+	}{
+		{"delimited", ProcedureConfig{Format: "delimited", Delimiter: "|"}, "1|10.25\n2|\n3|-0.25\n", 3, "10"},
+		{"fixed", ProcedureConfig{Format: "fixed"}, "1  0001025+\n2  0000025-\n", 2, "10"},
+		{"jsonl", ProcedureConfig{Format: "jsonl"}, `{"ID":1,"AMOUNT":1.5}` + "\n" + `{"ID":2,"AMOUNT":null}` + "\n", 2, "1.5"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "P.txt")
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := fileTotals(path, &tt.proc, cols, []int{1}, false)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got.Rows != tt.rows || formatRat(got.Sums[0]) != tt.sum {
+			t.Errorf("%s: %d rows summing to %s, want %d and %s", tt.name, got.Rows, formatRat(got.Sums[0]), tt.rows, tt.sum)
+		}
+	}
+}
+
+func TestReadExtractLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "extract.csv")
+	data := "SOL_ID,PROCEDURE,STATUS,ROWS\n" +
+		"01,P,SUCCESS,5\n" +
+		"02,P,FAILED,0\n" +
+		"01,P,SUCCESS,7\n" +
+		"03,P,SUCCESS,-\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readExtractLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{checkpointKey("01", "P"): 7}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readExtractLog = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// RetryConfig controls how transient database errors are retried.
+type RetryConfig struct {
+	MaxAttempts      int     `json:"max_attempts"`
+	InitialBackoffMs int     `json:"initial_backoff_ms"`
+	MaxBackoffMs     int     `json:"max_backoff_ms"`
+	Multiplier       float64 `json:"multiplier"`
+	// TransientCodes adds ORA error numbers to the built-in transient set.
+	TransientCodes []int `json:"transient_codes"`
+}
+
+// transientOraCodes are errors raised before the call could take effect, or
+// after Oracle undid it, that usually clear up when the call is repeated:
+// deadlocks, lock and resource waits, failed connects and instance restarts.
+var transientOraCodes = map[int]bool{
+	51:    true, // timeout occurred while waiting for a resource
+	60:    true, // deadlock detected while waiting for resource
+	1033:  true, // ORACLE initialization or shutdown in progress
+	1034:  true, // ORACLE not available
+	1089:  true, // immediate shutdown or close in progress
+	1555:  true, // snapshot too old
+	3114:  true, // not connected to ORACLE
+	4061:  true, // existing state of package has been invalidated
+	4068:  true, // existing state of packages has been discarded
+	12170: true, // TNS:Connect timeout occurred
+	12514: true, // TNS:listener does not currently know of service
+	12516: true, // TNS:listener could not find available handler
+	12519: true, // TNS:no appropriate service handler found
+	12520: true, // TNS:listener could not find available handler for requested type of server
+	12528: true, // TNS:listener: all appropriate instances are blocking new connections
+	12541: true, // TNS:no listener
+	30006: true, // resource busy; acquire with WAIT timeout expired
+}
+
+// lostConnectionOraCodes are errors from a connection lost during the call,
+// which leave it unknown whether the call took effect. They are only retried
+// when repeating the call is safe.
+var lostConnectionOraCodes = map[int]bool{
+	3113:  true, // end-of-file on communication channel
+	3135:  true, // connection lost contact
+	12537: true, // TNS:connection closed
+	12547: true, // TNS:lost contact
+	12571: true, // TNS:packet writer failure
+}
+
+var oraCodeRe = regexp.MustCompile(`ORA-(\d{5})`)
+
+// oraCode extracts the ORA error number from err, or 0 when there is none.
+func oraCode(err error) int {
+	var coder interface{ Code() int }
+	if errors.As(err, &coder) && coder.Code() != 0 {
+		return coder.Code()
+	}
+	if m := oraCodeRe.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code
+	}
+	return 0
+}
+
+// isTransient classifies err as worth retrying. Cancellations and timeouts are
+// never retried; anything without a known transient ORA code is permanent. A
+// lost connection is only transient when replaySafe says the call may run
+// twice.
+func (rc RetryConfig) isTransient(err error, replaySafe bool) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		// database/sql drivers only return ErrBadConn for calls not sent.
+		return true
+	}
+	code := oraCode(err)
+	if code == 0 {
+		return false
+	}
+	if transientOraCodes[code] || replaySafe && lostConnectionOraCodes[code] {
+		return true
+	}
+	for _, c := range rc.TransientCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (rc *RetryConfig) applyDefaults() {
+	if rc.MaxAttempts <= 0 {
+		rc.MaxAttempts = 3
+	}
+	if rc.InitialBackoffMs <= 0 {
+		rc.InitialBackoffMs = 1000
+	}
+	if rc.MaxBackoffMs <= 0 {
+		rc.MaxBackoffMs = 30000
+	}
+	if rc.Multiplier < 1 {
+		rc.Multiplier = 2
+	}
+}
+
+// backoff is the wait after the given failed attempt: the initial backoff
+// grown by the multiplier for each earlier attempt, up to the maximum.
+func (rc RetryConfig) backoff(attempt int) time.Duration {
+	d := time.Duration(rc.InitialBackoffMs) * time.Millisecond
+	maxBackoff := time.Duration(rc.MaxBackoffMs) * time.Millisecond
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d = time.Duration(float64(d) * rc.Multiplier)
+	}
+	return min(d, maxBackoff)
+}
+
+// withRetry runs fn until it succeeds, fails permanently or runs out of
+// attempts, sleeping with exponential backoff between transient failures.
+// replaySafe allows retrying after a lost connection, which may repeat a call
+// that already took effect. It returns the number of attempts made.
+func withRetry(ctx context.Context, rc RetryConfig, desc string, replaySafe bool, fn func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= rc.MaxAttempts || !rc.isTransient(err, replaySafe) {
+			return attempt, err
+		}
+		backoff := rc.backoff(attempt)
+		log.Printf("🔄 Transient error on %s (attempt %d/%d), retrying in %s: %v",
+			desc, attempt, rc.MaxAttempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			return attempt, err
+		case <-time.After(backoff):
+		}
+	}
+}
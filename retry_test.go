@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// codeError carries an ORA code the way godror's errors do.
+type codeError int
+
+func (e codeError) Error() string { return fmt.Sprintf("error %d", int(e)) }
+func (e codeError) Code() int     { return int(e) }
+
+func TestOraCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("ORA-00060: deadlock detected while waiting for resource"), 60},
+		{fmt.Errorf("call failed: %w", errors.New("ORA-03113: end-of-file on communication channel")), 3113},
+		{fmt.Errorf("call failed: %w", codeError(12541)), 12541},
+		{errors.New("ORA-06512: at line 1\nORA-01403: no data found"), 6512},
+		{errors.New("no code here"), 0},
+	}
+	for _, tt := range tests {
+		if got := oraCode(tt.err); got != tt.want {
+			t.Errorf("oraCode(%q) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	rc := RetryConfig{TransientCodes: []int{20001}}
+	tests := []struct {
+		name       string
+		err        error
+		replaySafe bool
+		want       bool
+	}{
+		{"deadlock", errors.New("ORA-00060: deadlock detected"), false, true},
+		{"no listener", codeError(12541), false, true},
+		{"configured code", errors.New("ORA-20001: busy, try later"), false, true},
+		{"permanent", errors.New("ORA-00942: table or view does not exist"), true, false},
+		{"no code", errors.New("something else"), true, false},
+		{"bad connection", fmt.Errorf("query: %w", driver.ErrBadConn), false, true},
+		{"cancelled", fmt.Errorf("ORA-01013: %w", context.Canceled), true, false},
+		{"deadline", context.DeadlineExceeded, true, false},
+		{"lost connection", errors.New("ORA-03113: end-of-file on communication channel"), false, false},
+		{"lost connection replayed", errors.New("ORA-03113: end-of-file on communication channel"), true, true},
+		{"lost contact", codeError(3135), false, false},
+		{"lost contact replayed", codeError(3135), true, true},
+		{"unsafe replay", errors.New("ORA-25408: can not safely replay call"), true, false},
+	}
+	for _, tt := range tests {
+		if got := rc.isTransient(tt.err, tt.replaySafe); got != tt.want {
+			t.Errorf("%s: isTransient(replaySafe %v) = %v, want %v", tt.name, tt.replaySafe, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	rc := RetryConfig{InitialBackoffMs: 1000, MaxBackoffMs: 30000, Multiplier: 2}
+	want := []time.Duration{1, 2, 4, 8, 16, 30, 30}
+	for i, w := range want {
+		if got := rc.backoff(i + 1); got != w*time.Second {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, w*time.Second)
+		}
+	}
+	if got := rc.backoff(1000); got != 30*time.Second {
+		t.Errorf("backoff(1000) = %s, want the maximum", got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	rc := RetryConfig{MaxAttempts: 3, InitialBackoffMs: 1, MaxBackoffMs: 2, Multiplier: 2}
+	deadlock := errors.New("ORA-00060: deadlock detected")
+	tests := []struct {
+		name       string
+		errs       []error
+		want       int
+		wantErr    error
+		replaySafe bool
+	}{
+		{"succeeds first time", []error{nil}, 1, nil, false},
+		{"succeeds after transient", []error{deadlock, deadlock, nil}, 3, nil, false},
+		{"gives up", []error{deadlock, deadlock, deadlock, nil}, 3, deadlock, false},
+		{"permanent", []error{errors.New("ORA-00942"), nil}, 1, nil, false},
+		{"lost connection not replayed", []error{codeError(3113), nil}, 1, nil, false},
+		{"lost connection replayed", []error{codeError(3113), nil}, 2, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			attempts, err := withRetry(context.Background(), rc, "test", tt.replaySafe, func() error {
+				calls++
+				return tt.errs[calls-1]
+			})
+			if attempts != tt.want || calls != tt.want {
+				t.Fatalf("%d attempts and %d calls, want %d", attempts, calls, tt.want)
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == len(tt.errs) && err != nil {
+				t.Fatalf("error %v after the last call succeeded", err)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsOnCancel(t *testing.T) {
+	rc := RetryConfig{MaxAttempts: 5, InitialBackoffMs: 60000, MaxBackoffMs: 60000, Multiplier: 1}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	attempts, err := withRetry(ctx, rc, "test", false, func() error {
+		calls++
+		cancel()
+		return errors.New("ORA-00060: deadlock detected")
+	})
+	if attempts != 1 || calls != 1 || err == nil {
+		t.Fatalf("%d attempts, %d calls, error %v; want one failed attempt", attempts, calls, err)
+	}
+}
@@ -5,73 +5,70 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"runtime"
-	"sync"
 	"time"
 )
 
-func runProceduresForSol(ctx context.Context, db *sql.DB, solID string, procConfig *ExtractionConfig, logCh chan<- ProcLog, mu *sync.Mutex, summary map[string]ProcSummary) {
-	var wg sync.WaitGroup
-	procCh := make(chan string)
-
-	numWorkers := runtime.NumCPU() * 2
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for proc := range procCh {
-				start := time.Now()
-				log.Printf("🔁 Inserting: %s.%s for SOL %s", procConfig.PackageName, proc, solID)
-				err := callProcedure(ctx, db, procConfig.PackageName, proc, solID)
-				end := time.Now()
+// dbConn is what a procedure call needs from the database. *sql.DB, *sql.Conn
+// and *sql.Tx all qualify; DBMS_OUTPUT capture needs a single session.
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
 
-				plog := ProcLog{
-					SolID:         solID,
-					Procedure:     proc,
-					StartTime:     start,
-					EndTime:       end,
-					ExecutionTime: end.Sub(start),
-				}
-				if err != nil {
-					plog.Status = "FAIL"
-					plog.ErrorDetails = err.Error()
-				} else {
-					plog.Status = "SUCCESS"
-				}
-				logCh <- plog
+// callProcedure runs a procedure for one SOL on a pooled connection.
+func callProcedure(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, solID string) (callResult, error) {
+	if !cfg.CaptureDbmsOutput {
+		return execProcedure(ctx, db, cfg, proc, solID)
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return callResult{}, err
+	}
+	defer conn.Close()
+	return execProcedure(ctx, conn, cfg, proc, solID)
+}
 
-				mu.Lock()
-				s, exists := summary[proc]
-				if !exists {
-					s = ProcSummary{Procedure: proc, StartTime: start, EndTime: end, Status: plog.Status}
-				} else {
-					if start.Before(s.StartTime) {
-						s.StartTime = start
-					}
-					if end.After(s.EndTime) {
-						s.EndTime = end
-					}
-					if s.Status != "FAIL" && plog.Status == "FAIL" {
-						s.Status = "FAIL"
-					}
-				}
-				summary[proc] = s
-				mu.Unlock()
-			}
-		}()
+// execProcedure runs a procedure for one SOL on conn and returns what it
+// reported through its OUT arguments, failing the call when its status says so.
+func execProcedure(ctx context.Context, conn dbConn, cfg *ExtractionConfig, proc *ProcedureConfig, solID string) (callResult, error) {
+	query := procedureCall(cfg, proc)
+	args, outs, err := procedureArgs(cfg, proc, query, solID)
+	if err != nil {
+		return callResult{}, err
 	}
+	start := time.Now()
+	var output string
+	if cfg.CaptureDbmsOutput {
+		output, err = withDbmsOutput(ctx, conn, cfg.DbmsOutputMaxBytes, func() error {
+			_, err := conn.ExecContext(ctx, query, args...)
+			return err
+		})
+	} else {
+		_, err = conn.ExecContext(ctx, query, args...)
+	}
+	log.Printf("✅ Finished: %s.%s for SOL %s in %s", cfg.PackageName, proc.Name, solID, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		return callResult{Output: output}, err
+	}
+	res, err := checkOutValues(proc, outs)
+	res.Output = output
+	return res, err
+}
 
-	for _, proc := range procConfig.Procedures {
-		procCh <- proc
+// procedureArgs binds a procedure call for one SOL, from its signature when it
+// has one.
+func procedureArgs(cfg *ExtractionConfig, proc *ProcedureConfig, query, solID string) ([]interface{}, []outValue, error) {
+	if len(proc.Signature) > 0 {
+		return signatureArgs(cfg, proc, solID)
 	}
-	close(procCh)
-	wg.Wait()
+	args, err := bindArgs(cfg, proc, query, solID)
+	return args, nil, err
 }
 
-func callProcedure(ctx context.Context, db *sql.DB, pkgName, procName, solID string) error {
-	query := fmt.Sprintf("BEGIN %s.%s(:1); END;", pkgName, procName)
-	start := time.Now()
-	_, err := db.ExecContext(ctx, query, solID)
-	log.Printf("✅ Finished: %s.%s for SOL %s in %s", pkgName, procName, solID, time.Since(start).Round(time.Millisecond))
-	return err
+// procedureCall returns the PL/SQL block that runs a procedure of the package.
+func procedureCall(cfg *ExtractionConfig, proc *ProcedureConfig) string {
+	if binds := callPlaceholders(proc); binds != "" {
+		return fmt.Sprintf("BEGIN %s.%s(%s); END;", cfg.PackageName, proc.Name, binds)
+	}
+	return fmt.Sprintf("BEGIN %s.%s; END;", cfg.PackageName, proc.Name)
 }
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// columnKind is how a result column is scanned for typed output.
+type columnKind int
+
+const (
+	textKind columnKind = iota
+	numberKind
+	boolKind
+	timeKind
+)
+
+// columnKinds classifies result columns by their database type.
+func columnKinds(types []*sql.ColumnType) []columnKind {
+	kinds := make([]columnKind, len(types))
+	for i, ct := range types {
+		name := ct.DatabaseTypeName()
+		switch {
+		case dbColumn{DataType: name}.isNumeric():
+			kinds[i] = numberKind
+		case name == "BOOLEAN":
+			kinds[i] = boolKind
+		case name == "DATE", strings.HasPrefix(name, "TIMESTAMP"):
+			kinds[i] = timeKind
+		}
+	}
+	return kinds
+}
+
+// resultKinds classifies result columns by the type their template column
+// declares, falling back to the database type.
+func resultKinds(cols []ColumnConfig, types []*sql.ColumnType) []columnKind {
+	kinds := columnKinds(types)
+	for i, col := range cols {
+		switch col.Type {
+		case "string":
+			kinds[i] = textKind
+		case "number":
+			kinds[i] = numberKind
+		case "boolean":
+			kinds[i] = boolKind
+		case "date":
+			kinds[i] = timeKind
+		}
+	}
+	return kinds
+}
+
+// scanTyped reads the current row into native values: nil for NULL, int64
+// for integers that fit, float64 for binary floating point, *big.Rat for other
+// numbers, bool, time.Time, and string for everything else. Numbers and
+// booleans are never formatted by the session, so the output does not depend
+// on its NLS settings.
+func scanTyped(rows *sql.Rows, kinds []columnKind, types []*sql.ColumnType) ([]interface{}, error) {
+	dest := make([]interface{}, len(kinds))
+	for i, k := range kinds {
+		switch {
+		case k == timeKind:
+			dest[i] = new(sql.NullTime)
+		case k == numberKind && isBinaryFloat(types[i]):
+			dest[i] = new(sql.NullFloat64)
+		case k == numberKind && isSmallInteger(types[i]):
+			dest[i] = new(sql.NullInt64)
+		default:
+			dest[i] = new(sql.NullString)
+		}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(kinds))
+	for i, d := range dest {
+		var err error
+		switch d := d.(type) {
+		case *sql.NullTime:
+			if d.Valid {
+				values[i] = d.Time
+			}
+		case *sql.NullFloat64:
+			if d.Valid {
+				values[i] = d.Float64
+			}
+		case *sql.NullInt64:
+			if d.Valid {
+				values[i] = d.Int64
+			}
+		case *sql.NullString:
+			if d.Valid {
+				values[i], err = parseScanned(d.String, kinds[i])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", types[i].Name(), err)
+		}
+	}
+	return values, nil
+}
+
+// isBinaryFloat reports whether a column is a BINARY_FLOAT or BINARY_DOUBLE,
+// which godror names FLOAT and DOUBLE; Oracle's FLOAT is reported as NUMBER.
+func isBinaryFloat(ct *sql.ColumnType) bool {
+	name := ct.DatabaseTypeName()
+	return name == "FLOAT" || name == "DOUBLE"
+}
+
+// isSmallInteger reports whether a column is a NUMBER(p) whose values fit an
+// int64.
+func isSmallInteger(ct *sql.ColumnType) bool {
+	p, s, ok := ct.DecimalSize()
+	return ok && ct.DatabaseTypeName() == "NUMBER" && s == 0 && p > 0 && p <= 18
+}
+
+// parseScanned converts the text of a number or boolean column.
+func parseScanned(s string, kind columnKind) (interface{}, error) {
+	switch kind {
+	case numberKind:
+		// Oracle writes fractions below one without the leading zero,
+		// which big.Rat accepts.
+		r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+		if !ok {
+			return nil, fmt.Errorf("%q is not a number", s)
+		}
+		return r, nil
+	case boolKind:
+		switch strings.ToUpper(strings.TrimSpace(s)) {
+		case "1", "Y", "YES", "TRUE", "T":
+			return true, nil
+		case "0", "N", "NO", "FALSE", "F":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", s)
+	}
+	return s, nil
+}
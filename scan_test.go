@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+)
+
+// typesDriver serves queries of the form "TYPE,TYPE,..." with one row of
+// typedRow values, reporting each column's database type name as given, the
+// way godror would.
+type typesDriver struct{}
+
+type typesConn struct{}
+
+type typesStmt struct{ query string }
+
+type typesRows struct {
+	types []string
+	done  bool
+}
+
+// typedRow holds the values a typesDriver query returns, by type name.
+var typedRow = map[string]driver.Value{
+	"NUMBER":                   "-.5",
+	"FLOAT":                    float64(0.25),
+	"DOUBLE":                   float64(2.675),
+	"BINARY_INTEGER":           int64(7),
+	"VARCHAR2":                 "text",
+	"DATE":                     nil,
+	"TIMESTAMP WITH TIME ZONE": nil,
+	"BOOLEAN":                  "1",
+}
+
+func init() { sql.Register("types", typesDriver{}) }
+
+func (typesDriver) Open(string) (driver.Conn, error)         { return typesConn{}, nil }
+func (typesConn) Prepare(query string) (driver.Stmt, error)  { return typesStmt{query}, nil }
+func (typesConn) Close() error                               { return nil }
+func (typesConn) Begin() (driver.Tx, error)                  { return nil, driver.ErrSkip }
+func (typesStmt) Close() error                               { return nil }
+func (typesStmt) NumInput() int                              { return 0 }
+func (typesStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s typesStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &typesRows{types: strings.Split(s.query, ",")}, nil
+}
+func (r *typesRows) Columns() []string                       { return r.types }
+func (r *typesRows) Close() error                            { return nil }
+func (r *typesRows) ColumnTypeDatabaseTypeName(i int) string { return r.types[i] }
+func (r *typesRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	for i, name := range r.types {
+		dest[i] = typedRow[name]
+	}
+	return nil
+}
+
+// queryTypes runs a typesDriver query for the given type names.
+func queryTypes(t *testing.T, names ...string) (*sql.Rows, []*sql.ColumnType) {
+	t.Helper()
+	db, err := sql.Open("types", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.Query(strings.Join(names, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rows.Close() })
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows, types
+}
+
+func TestColumnKindsDriverNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  columnKind
+		float bool
+	}{
+		{"NUMBER", numberKind, false},
+		{"FLOAT", numberKind, true},
+		{"DOUBLE", numberKind, true},
+		{"BINARY_INTEGER", numberKind, false},
+		{"VARCHAR2", textKind, false},
+		{"DATE", timeKind, false},
+		{"TIMESTAMP WITH TIME ZONE", timeKind, false},
+		{"BOOLEAN", boolKind, false},
+	}
+	names := make([]string, len(tests))
+	for i, tt := range tests {
+		names[i] = tt.name
+	}
+	_, types := queryTypes(t, names...)
+	kinds := columnKinds(types)
+	for i, tt := range tests {
+		if kinds[i] != tt.kind {
+			t.Errorf("%s: kind %d, want %d", tt.name, kinds[i], tt.kind)
+		}
+		if got := isBinaryFloat(types[i]); got != tt.float {
+			t.Errorf("%s: isBinaryFloat() = %v, want %v", tt.name, got, tt.float)
+		}
+	}
+}
+
+func TestScanTypedBinaryDouble(t *testing.T) {
+	rows, types := queryTypes(t, "DOUBLE", "NUMBER", "VARCHAR2")
+	kinds := columnKinds(types)
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	values, err := scanTyped(rows, kinds, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := values[0].(float64); !ok || v != 2.675 {
+		t.Errorf("DOUBLE scanned as %T %v, want float64 2.675", values[0], values[0])
+	}
+	if v, ok := values[1].(interface{ FloatString(int) string }); !ok || v.FloatString(1) != "-0.5" {
+		t.Errorf("NUMBER scanned as %T %v, want -0.5", values[1], values[1])
+	}
+	col := ColumnConfig{Decimals: 2, ImpliedScale: -1}
+	if got := col.formatNumber(values[0]); got != "2.68" {
+		t.Errorf("DOUBLE formatted as %q, want 2.68", got)
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// task is one unit of scheduled work: a procedure for one SOL, or a global
+// procedure run once under globalSol.
+type task struct {
+	SolID string
+	Proc  *ProcedureConfig
+	// Procs, set instead of Proc, are run in order for the SOL in a single
+	// transaction.
+	Procs []ProcedureConfig
+	// skip explains why the task must not run, set when a prerequisite of
+	// the same SOL did not succeed.
+	skip string
+}
+
+// solTasks lists every (SOL, procedure) pair, SOL by SOL.
+func solTasks(sols []string, procs []ProcedureConfig) []task {
+	tasks := make([]task, 0, len(sols)*len(procs))
+	for _, solID := range sols {
+		for i := range procs {
+			tasks = append(tasks, task{SolID: solID, Proc: &procs[i]})
+		}
+	}
+	return tasks
+}
+
+// queue names the scheduler queue of a task: its procedure, or one shared
+// queue for SOL transactions, which are not subject to procedure caps or
+// dependencies.
+func (t task) queue() string {
+	if t.Proc == nil {
+		return ""
+	}
+	return t.Proc.Name
+}
+
+// globalTasks wraps global procedures as tasks.
+func globalTasks(procs []ProcedureConfig) []task {
+	return solTasks([]string{globalSol}, procs)
+}
+
+// procQueue holds the tasks of one procedure whose prerequisites have
+// finished, as a heap of task indexes so the earliest is started first.
+type procQueue struct {
+	ready taskHeap
+	limit int
+}
+
+// taskHeap is a min-heap of indexes into scheduler.tasks.
+type taskHeap []int
+
+func (h taskHeap) Len() int            { return len(h) }
+func (h taskHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h taskHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *taskHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// scheduler hands tasks from a single queue to a fixed number of workers. A
+// task is only started while its procedure is below its max_concurrency and
+// once its prerequisites have finished for the same SOL, so a heavy or
+// blocked procedure cannot hold up the others. Each task counts its
+// unfinished prerequisites and joins its procedure's ready heap when the
+// last one finishes, so picking a task never rescans the waiting ones.
+type scheduler struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	tasks   []task
+	queues  map[string]*procQueue
+	order   []string
+	running map[string]int
+	deps    map[string][]string
+	results map[string]string
+	// waiting counts the unfinished prerequisites of each task, and waiters
+	// lists the tasks waiting on each (SOL, procedure) pair.
+	waiting []int
+	waiters map[string][]int
+	// left is the number of tasks not yet started.
+	left    int
+	stopped bool
+}
+
+// newScheduler queues tasks in the given order. results holds the final
+// status of tasks run earlier, so prerequisites may come from a previous
+// stage; the scheduler adds to it as tasks finish.
+func newScheduler(tasks []task, deps map[string][]string, results map[string]string) *scheduler {
+	s := &scheduler{
+		tasks:   make([]task, len(tasks)),
+		queues:  make(map[string]*procQueue),
+		running: make(map[string]int),
+		deps:    deps,
+		results: results,
+		waiting: make([]int, len(tasks)),
+		waiters: make(map[string][]int),
+		left:    len(tasks),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	for i, t := range tasks {
+		name := t.queue()
+		q, ok := s.queues[name]
+		if !ok {
+			q = &procQueue{}
+			if t.Proc != nil {
+				q.limit = t.Proc.MaxConcurrency
+			}
+			s.queues[name] = q
+			s.order = append(s.order, name)
+		}
+		for _, dep := range s.deps[name] {
+			key := checkpointKey(t.SolID, dep)
+			if status, done := s.results[key]; done {
+				t.skip = skipReason(t.skip, dep, status)
+				continue
+			}
+			s.waiting[i]++
+			s.waiters[key] = append(s.waiters[key], i)
+		}
+		s.tasks[i] = t
+		if s.waiting[i] == 0 {
+			heap.Push(&q.ready, i)
+		}
+	}
+	return s
+}
+
+// skipReason keeps the first reason to skip a task, adding one when a
+// prerequisite did not succeed.
+func skipReason(skip, dep, status string) string {
+	if skip != "" || status == "SUCCESS" {
+		return skip
+	}
+	return fmt.Sprintf("prerequisite %s finished with status %s", dep, status)
+}
+
+// next blocks until a queued task may start and takes it off the queue. It
+// returns false once every task has started or dispatch has been stopped.
+func (s *scheduler) next() (task, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.stopped || s.left == 0 {
+			return task{}, false
+		}
+		var best *procQueue
+		for _, name := range s.order {
+			q := s.queues[name]
+			if len(q.ready) == 0 || q.limit > 0 && s.running[name] >= q.limit {
+				continue
+			}
+			if best == nil || q.ready[0] < best.ready[0] {
+				best = q
+			}
+		}
+		if best != nil {
+			t := s.tasks[heap.Pop(&best.ready).(int)]
+			s.left--
+			s.running[t.queue()]++
+			return t, true
+		}
+		s.cond.Wait()
+	}
+}
+
+// done records the final status of a task, releases its procedure slot and
+// readies the tasks that were waiting only on it.
+func (s *scheduler) done(t task, status string) {
+	s.mu.Lock()
+	s.running[t.queue()]--
+	if t.Proc != nil {
+		key := checkpointKey(t.SolID, t.Proc.Name)
+		s.results[key] = status
+		for _, i := range s.waiters[key] {
+			s.tasks[i].skip = skipReason(s.tasks[i].skip, t.Proc.Name, status)
+			if s.waiting[i]--; s.waiting[i] == 0 {
+				heap.Push(&s.queues[s.tasks[i].queue()].ready, i)
+			}
+		}
+		delete(s.waiters, key)
+	}
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// stop makes next return false; tasks already started run to completion.
+func (s *scheduler) stop() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.cond.Broadcast()
+}
+
+// run executes the queued tasks on the given number of workers and returns
+// once every started task has finished. Tasks still queued when dispatchCtx
+// is done are never started. fn returns the task's final status.
+func (s *scheduler) run(dispatchCtx context.Context, workers int, fn func(task) string) {
+	stop := context.AfterFunc(dispatchCtx, s.stop)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				t, ok := s.next()
+				if !ok {
+					return
+				}
+				s.done(t, fn(t))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// taskRunner executes tasks in the current mode and records each outcome in
+// the log, the summary and the checkpoint.
+type taskRunner struct {
+	db        *sql.DB
+	cfg       *ExtractionConfig
+	templates map[string][]ColumnConfig
+	queries   map[string]string
+	logCh     chan<- ProcLog
+	mu        *sync.Mutex
+	summary   map[string]ProcSummary
+	cp        *checkpoint
+	// files bounds the number of spool files open at once.
+	files chan struct{}
+	// output receives captured DBMS_OUTPUT; nil when capture is off.
+	output *outputLog
+
+	total     int
+	completed int
+	start     time.Time
+}
+
+// runTask runs one task and returns its final status.
+func (r *taskRunner) runTask(ctx context.Context, t task) string {
+	if t.Procs != nil {
+		return r.runSolTransaction(ctx, t)
+	}
+	proc, solID := t.Proc, t.SolID
+	defer r.progress()
+	if prev, ok := r.cp.completed(solID, proc.Name); ok {
+		if reason := spoolMissing(mode, proc, prev); reason != "" {
+			log.Printf("🔄 Rerunning %s for SOL %s, completed in a previous run but %s", proc.Name, solID, reason)
+		} else {
+			log.Printf("⏭️ Skipping %s for SOL %s, completed in a previous run", proc.Name, solID)
+			recordSummary(r.mu, r.summary, prev)
+			return prev.Status
+		}
+	}
+	start := time.Now()
+	if t.skip != "" {
+		log.Printf("⏭️ Skipping %s for SOL %s: %s", proc.Name, solID, t.skip)
+		plog := ProcLog{
+			SolID:        solID,
+			Procedure:    proc.Name,
+			StartTime:    start,
+			EndTime:      start,
+			Status:       "SKIPPED",
+			ErrorDetails: t.skip,
+		}
+		r.logCh <- plog
+		recordSummary(r.mu, r.summary, plog)
+		return plog.Status
+	}
+	var desc string
+	if mode == "E" {
+		log.Printf("📥 Extracting %s for SOL %s", proc.Name, solID)
+		desc = fmt.Sprintf("%s for SOL %s", proc.Name, solID)
+	} else {
+		log.Printf("🔁 Inserting: %s.%s for SOL %s", r.cfg.PackageName, proc.Name, solID)
+		desc = fmt.Sprintf("%s.%s for SOL %s", r.cfg.PackageName, proc.Name, solID)
+	}
+	var timedOut bool
+	var rowCount, byteCount int64
+	var outValues string
+	attempt := 0
+	// Extraction only reads and rewrites its spool, so it can always be
+	// repeated.
+	replaySafe := mode == "E" || proc.Idempotent
+	attempts, err := withRetry(ctx, r.cfg.Retry, desc, replaySafe, func() error {
+		var err error
+		timedOut, err = runWithTimeout(ctx, procTimeout(proc), func(ctx context.Context) error {
+			if mode == "E" {
+				var err error
+				rowCount, byteCount, err = r.extract(ctx, proc, solID)
+				return err
+			}
+			res, err := callProcedure(ctx, r.db, r.cfg, proc, solID)
+			rowCount, outValues = res.Rows, res.OutValues
+			attempt++
+			if r.output != nil && res.Output != "" {
+				r.output.write(solID, proc.Name, attempt, res.Output)
+			}
+			return err
+		})
+		return err
+	})
+	end := time.Now()
+
+	plog := ProcLog{
+		SolID:         solID,
+		Procedure:     proc.Name,
+		StartTime:     start,
+		EndTime:       end,
+		ExecutionTime: end.Sub(start),
+		Attempts:      attempts,
+		Rows:          rowCount,
+		Bytes:         byteCount,
+		OutValues:     outValues,
+		Status:        procStatus(ctx, err, timedOut),
+	}
+	if err != nil {
+		plog.ErrorDetails = err.Error()
+	}
+	r.logCh <- plog
+
+	if plog.Status == "SUCCESS" {
+		if err := r.cp.record(plog); err != nil {
+			log.Printf("⚠️ Failed to checkpoint %s for SOL %s: %v", proc.Name, solID, err)
+		}
+	}
+	recordSummary(r.mu, r.summary, plog)
+	if mode == "E" {
+		log.Printf("✅ Completed %s for SOL %s in %s (%d rows)", proc.Name, solID, end.Sub(start).Round(time.Millisecond), rowCount)
+	}
+	return plog.Status
+}
+
+// procStatus classifies the outcome of a call.
+func procStatus(ctx context.Context, err error, timedOut bool) string {
+	switch {
+	case err == nil:
+		return "SUCCESS"
+	case timedOut:
+		return "TIMEOUT"
+	case ctx.Err() != nil:
+		return "CANCELLED"
+	}
+	return "FAIL"
+}
+
+// extract runs one extraction once a spool file slot is free.
+func (r *taskRunner) extract(ctx context.Context, proc *ProcedureConfig, solID string) (int64, int64, error) {
+	select {
+	case r.files <- struct{}{}:
+	case <-ctx.Done():
+		return 0, 0, ctx.Err()
+	}
+	defer func() { <-r.files }()
+	return extractData(ctx, r.db, r.cfg, proc, solID, r.templates, r.queries)
+}
+
+// progress logs the share of tasks finished every 100 tasks and at the end.
+func (r *taskRunner) progress() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.completed++
+	if r.completed%100 != 0 && r.completed != r.total {
+		return
+	}
+	elapsed := time.Since(r.start)
+	log.Printf("✅ Progress: %d/%d tasks (%.2f%%) | Elapsed: %s | ETA: %s",
+		r.completed, r.total, float64(r.completed)*100/float64(r.total),
+		elapsed.Round(time.Second), remaining(elapsed, r.completed, r.total).Round(time.Second))
+}
+
+// remaining estimates the time left to finish total tasks, assuming the rest
+// take as long on average as the completed ones.
+func remaining(elapsed time.Duration, completed, total int) time.Duration {
+	if completed <= 0 || completed >= total {
+		return 0
+	}
+	return time.Duration(float64(elapsed) / float64(completed) * float64(total-completed))
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testProcs(names ...string) []ProcedureConfig {
+	procs := make([]ProcedureConfig, len(names))
+	for i, name := range names {
+		procs[i] = ProcedureConfig{Name: name}
+	}
+	return procs
+}
+
+func testSols(n int) []string {
+	sols := make([]string, n)
+	for i := range sols {
+		sols[i] = fmt.Sprintf("%04d", i+1)
+	}
+	return sols
+}
+
+func TestSchedulerRunsEveryTaskOnce(t *testing.T) {
+	tasks := solTasks(testSols(50), testProcs("A", "B", "C"))
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	newScheduler(tasks, nil, map[string]string{}).run(context.Background(), 8, func(tk task) string {
+		mu.Lock()
+		runs[checkpointKey(tk.SolID, tk.Proc.Name)]++
+		mu.Unlock()
+		return "SUCCESS"
+	})
+	if len(runs) != len(tasks) {
+		t.Fatalf("ran %d distinct tasks, want %d", len(runs), len(tasks))
+	}
+	for key, n := range runs {
+		if n != 1 {
+			t.Errorf("task %q ran %d times", key, n)
+		}
+	}
+}
+
+func TestSchedulerKeepsOrderWithOneWorker(t *testing.T) {
+	tasks := solTasks(testSols(3), testProcs("A", "B"))
+	var got []string
+	newScheduler(tasks, nil, map[string]string{}).run(context.Background(), 1, func(tk task) string {
+		got = append(got, tk.SolID+"/"+tk.Proc.Name)
+		return "SUCCESS"
+	})
+	want := []string{"0001/A", "0001/B", "0002/A", "0002/B", "0003/A", "0003/B"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("order %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerMaxConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		workers int
+		want    int
+	}{
+		{"capped", 2, 6, 2},
+		{"single", 1, 4, 1},
+		{"uncapped", 0, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procs := testProcs("HEAVY", "LIGHT")
+			procs[0].MaxConcurrency = tt.limit
+			tasks := solTasks(testSols(30), procs)
+
+			var mu sync.Mutex
+			running, peak := 0, 0
+			newScheduler(tasks, nil, map[string]string{}).run(context.Background(), tt.workers, func(tk task) string {
+				if tk.Proc.Name != "HEAVY" {
+					return "SUCCESS"
+				}
+				mu.Lock()
+				running++
+				peak = max(peak, running)
+				mu.Unlock()
+				time.Sleep(2 * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return "SUCCESS"
+			})
+			if peak > tt.want {
+				t.Fatalf("%d HEAVY tasks ran at once, limit %d", peak, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchedulerStopDrainsStartedTasks(t *testing.T) {
+	tasks := solTasks(testSols(100), testProcs("A"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	started, finished := 0, 0
+	newScheduler(tasks, nil, map[string]string{}).run(ctx, 4, func(tk task) string {
+		mu.Lock()
+		started++
+		if started == 10 {
+			cancel()
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		finished++
+		mu.Unlock()
+		return "SUCCESS"
+	})
+	if started != finished {
+		t.Fatalf("run returned with %d of %d started tasks finished", finished, started)
+	}
+	if started >= len(tasks) {
+		t.Fatalf("all %d tasks started after stop", started)
+	}
+}
+
+func TestSchedulerDependencies(t *testing.T) {
+	procs := testProcs("A", "B", "C")
+	deps := map[string][]string{"B": {"A"}, "C": {"B"}}
+	tasks := solTasks(testSols(20), procs)
+	results := make(map[string]string)
+
+	var mu sync.Mutex
+	finished := make(map[string]bool)
+	skipped := make(map[string]string)
+	newScheduler(tasks, deps, results).run(context.Background(), 6, func(tk task) string {
+		mu.Lock()
+		defer mu.Unlock()
+		key := checkpointKey(tk.SolID, tk.Proc.Name)
+		for _, dep := range deps[tk.Proc.Name] {
+			if !finished[checkpointKey(tk.SolID, dep)] {
+				t.Errorf("%s started for SOL %s before %s finished", tk.Proc.Name, tk.SolID, dep)
+			}
+		}
+		finished[key] = true
+		if tk.skip != "" {
+			skipped[key] = tk.skip
+			return "SKIPPED"
+		}
+		if tk.Proc.Name == "A" && tk.SolID == "0002" {
+			return "FAIL"
+		}
+		return "SUCCESS"
+	})
+
+	for _, name := range []string{"B", "C"} {
+		if _, ok := skipped[checkpointKey("0002", name)]; !ok {
+			t.Errorf("%s ran for SOL 0002 although A failed", name)
+		}
+	}
+	if len(skipped) != 2 {
+		t.Errorf("skipped %d tasks, want 2: %v", len(skipped), skipped)
+	}
+	if got := results[checkpointKey("0002", "C")]; got != "SKIPPED" {
+		t.Errorf("C for SOL 0002 finished %q, want SKIPPED", got)
+	}
+}
+
+func TestSchedulerPrerequisiteFromEarlierBatch(t *testing.T) {
+	results := map[string]string{
+		checkpointKey("0001", "A"): "SUCCESS",
+		checkpointKey("0002", "A"): "TIMEOUT",
+	}
+	deps := map[string][]string{"B": {"A"}}
+	var mu sync.Mutex
+	skips := make(map[string]string)
+	newScheduler(solTasks(testSols(2), testProcs("B")), deps, results).run(context.Background(), 2, func(tk task) string {
+		mu.Lock()
+		skips[tk.SolID] = tk.skip
+		mu.Unlock()
+		return "SUCCESS"
+	})
+	if skips["0001"] != "" {
+		t.Errorf("SOL 0001 skipped: %s", skips["0001"])
+	}
+	if want := "prerequisite A finished with status TIMEOUT"; skips["0002"] != want {
+		t.Errorf("SOL 0002 skip %q, want %q", skips["0002"], want)
+	}
+}
+
+func BenchmarkSchedulerChainedDependencies(b *testing.B) {
+	procs := testProcs("A", "B", "C")
+	deps := map[string][]string{"B": {"A"}, "C": {"B"}}
+	sols := testSols(8000)
+	for i := 0; i < b.N; i++ {
+		newScheduler(solTasks(sols, procs), deps, map[string]string{}).run(context.Background(), 16, func(task) string {
+			return "SUCCESS"
+		})
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	tests := []struct {
+		elapsed          time.Duration
+		completed, total int
+		want             time.Duration
+	}{
+		{10 * time.Minute, 100, 400, 30 * time.Minute},
+		{10 * time.Minute, 300, 400, 10 * time.Minute / 3},
+		{time.Second, 1, 1, 0},
+		{time.Minute, 0, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := remaining(tt.elapsed, tt.completed, tt.total); got != tt.want {
+			t.Errorf("remaining(%s, %d, %d) = %s, want %s", tt.elapsed, tt.completed, tt.total, got, tt.want)
+		}
+	}
+}
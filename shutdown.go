@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// handleShutdown watches for SIGINT/SIGTERM. The first signal cancels the
+// returned dispatch context so no new work starts; in-flight work keeps the
+// work context until the grace period expires or a second signal arrives.
+func handleShutdown(grace time.Duration) (dispatchCtx, workCtx context.Context) {
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	workCtx, cancelWork := context.WithCancel(context.Background())
+
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		log.Printf("🛑 Received %s: no new work will start, waiting up to %s for in-flight work (signal again to force)", sig, grace)
+		stopDispatch()
+		select {
+		case <-sigCh:
+		case <-time.After(grace):
+		}
+		log.Printf("🛑 Cancelling in-flight work")
+		cancelWork()
+		// Restore the default behaviour so a further signal kills the process.
+		signal.Stop(sigCh)
+	}()
+	return dispatchCtx, workCtx
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+// ProcParam is one argument of a procedure's signature.
+type ProcParam struct {
+	Name string `json:"name"`
+	// Direction is in, out or in_out; Type is varchar2, number or date.
+	Direction string `json:"direction"`
+	Type      string `json:"type"`
+}
+
+// resolveSignature normalizes a procedure's signature and checks that the
+// status, message and rows parameters name OUT arguments of it.
+func (p *ProcedureConfig) resolveSignature() error {
+	if len(p.Signature) == 0 {
+		if p.StatusParam != "" || p.MessageParam != "" || p.RowsParam != "" {
+			return fmt.Errorf("%s sets status_param, message_param or rows_param without a signature", p.Name)
+		}
+		return nil
+	}
+	if len(p.Params) > 0 {
+		return fmt.Errorf("%s cannot combine params with a signature; list them as IN arguments instead", p.Name)
+	}
+	args := make(map[string]ProcParam)
+	for i := range p.Signature {
+		a := &p.Signature[i]
+		a.Name = strings.ToUpper(strings.TrimSpace(a.Name))
+		a.Direction = strings.ToLower(a.Direction)
+		a.Type = strings.ToLower(a.Type)
+		if a.Name == "" {
+			return fmt.Errorf("argument %d in the signature of %s has no name", i+1, p.Name)
+		}
+		if _, dup := args[a.Name]; dup {
+			return fmt.Errorf("argument %s appears more than once in the signature of %s", a.Name, p.Name)
+		}
+		if a.Direction == "" {
+			a.Direction = "in"
+		}
+		if a.Type == "" {
+			a.Type = "varchar2"
+		}
+		switch a.Direction {
+		case "in", "out", "in_out":
+		default:
+			return fmt.Errorf("invalid direction %q for %s of %s: valid values are 'in', 'out' and 'in_out'", a.Direction, a.Name, p.Name)
+		}
+		switch a.Type {
+		case "varchar2", "number", "date":
+		default:
+			return fmt.Errorf("invalid type %q for %s of %s: valid values are 'varchar2', 'number' and 'date'", a.Type, a.Name, p.Name)
+		}
+		if a.Direction == "in_out" && a.Type == "date" {
+			return fmt.Errorf("%s of %s: in_out date arguments are not supported", a.Name, p.Name)
+		}
+		args[a.Name] = *a
+	}
+
+	for _, ref := range []struct {
+		field string
+		name  *string
+	}{{"status_param", &p.StatusParam}, {"message_param", &p.MessageParam}, {"rows_param", &p.RowsParam}} {
+		if *ref.name == "" {
+			continue
+		}
+		*ref.name = strings.ToUpper(*ref.name)
+		if a, ok := args[*ref.name]; !ok || a.Direction == "in" {
+			return fmt.Errorf("%s of %s must name an OUT argument of its signature", ref.field, p.Name)
+		}
+	}
+	if p.RowsParam != "" && args[p.RowsParam].Type != "number" {
+		return fmt.Errorf("rows_param of %s must be a number argument", p.Name)
+	}
+	if p.StatusParam != "" && len(p.SuccessValues) == 0 {
+		p.SuccessValues = []string{"0"}
+	}
+	return nil
+}
+
+// outValue is the destination bound for one OUT argument.
+type outValue struct {
+	param ProcParam
+	dest  interface{}
+}
+
+func (o outValue) String() string {
+	switch d := o.dest.(type) {
+	case *string:
+		return *d
+	case *godror.Number:
+		return string(*d)
+	case *time.Time:
+		if d.IsZero() {
+			return ""
+		}
+		return d.Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+// signatureArgs binds a procedure's signature for one SOL: IN values are taken
+// from the SOL fields and run parameters, OUT arguments get a destination of
+// their type.
+func signatureArgs(cfg *ExtractionConfig, proc *ProcedureConfig, solID string) ([]interface{}, []outValue, error) {
+	values, err := partitionValues(cfg, proc, solID)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := make([]interface{}, 0, len(proc.Signature))
+	var outs []outValue
+	for _, a := range proc.Signature {
+		var in string
+		if a.Direction != "out" {
+			v, ok := values[a.Name]
+			if !ok {
+				v, ok = cfg.Params[a.Name]
+			}
+			if !ok {
+				return nil, nil, fmt.Errorf("no SOL field or run parameter for argument %s of %s", a.Name, proc.Name)
+			}
+			in = v
+		}
+		if a.Direction == "in" {
+			args = append(args, sql.Named(a.Name, in))
+			continue
+		}
+		var dest interface{}
+		switch a.Type {
+		case "number":
+			n := godror.Number(in)
+			dest = &n
+		case "date":
+			dest = new(time.Time)
+		default:
+			s := in
+			dest = &s
+		}
+		args = append(args, sql.Named(a.Name, sql.Out{Dest: dest, In: a.Direction == "in_out"}))
+		outs = append(outs, outValue{a, dest})
+	}
+	return args, outs, nil
+}
+
+// callResult is what a procedure reported through its OUT arguments and,
+// when captured, DBMS_OUTPUT.
+type callResult struct {
+	Rows      int64
+	OutValues string
+	Output    string
+}
+
+// checkOutValues collects the OUT values of a finished call and applies the
+// procedure's status rule.
+func checkOutValues(proc *ProcedureConfig, outs []outValue) (callResult, error) {
+	values := make(map[string]string, len(outs))
+	pairs := make([]string, len(outs))
+	for i, o := range outs {
+		values[o.param.Name] = o.String()
+		pairs[i] = o.param.Name + "=" + values[o.param.Name]
+	}
+	res := callResult{OutValues: strings.Join(pairs, ";")}
+	if proc.RowsParam != "" {
+		if n, err := strconv.ParseInt(values[proc.RowsParam], 10, 64); err == nil {
+			res.Rows = n
+		}
+	}
+	if proc.StatusParam != "" {
+		if status := values[proc.StatusParam]; !slices.Contains(proc.SuccessValues, status) {
+			msg := fmt.Sprintf("%s returned %s=%s", proc.Name, proc.StatusParam, status)
+			if m := values[proc.MessageParam]; proc.MessageParam != "" && m != "" {
+				msg += ": " + m
+			}
+			return res, errors.New(msg)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godror/godror"
+)
+
+func TestResolveSignature(t *testing.T) {
+	sig := func(args ...ProcParam) []ProcParam { return args }
+	tests := []struct {
+		name string
+		proc ProcedureConfig
+		err  string
+	}{
+		{"status and rows", ProcedureConfig{Signature: sig(ProcParam{Name: "sol_id"},
+			ProcParam{Name: "p_status", Direction: "OUT"}, ProcParam{Name: "p_rows", Direction: "out", Type: "number"}),
+			StatusParam: "P_STATUS", RowsParam: "p_rows"}, ""},
+		{"no signature", ProcedureConfig{StatusParam: "P_STATUS"}, "without a signature"},
+		{"with params", ProcedureConfig{Signature: sig(ProcParam{Name: "A"}), Params: []string{"A"}}, "cannot combine params"},
+		{"duplicate", ProcedureConfig{Signature: sig(ProcParam{Name: "A"}, ProcParam{Name: " a "})}, "A appears more than once"},
+		{"bad direction", ProcedureConfig{Signature: sig(ProcParam{Name: "A", Direction: "inout"})}, `invalid direction "inout"`},
+		{"bad type", ProcedureConfig{Signature: sig(ProcParam{Name: "A", Type: "clob"})}, `invalid type "clob"`},
+		{"in out date", ProcedureConfig{Signature: sig(ProcParam{Name: "A", Direction: "in_out", Type: "date"})}, "in_out date"},
+		{"status is in", ProcedureConfig{Signature: sig(ProcParam{Name: "A"}), StatusParam: "A"}, "status_param of P must name an OUT argument"},
+		{"rows not number", ProcedureConfig{Signature: sig(ProcParam{Name: "A", Direction: "out"}), RowsParam: "A"}, "must be a number"},
+	}
+	for _, tt := range tests {
+		tt.proc.Name = "P"
+		err := tt.proc.resolveSignature()
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestSignatureArgs(t *testing.T) {
+	cfg := &ExtractionConfig{SolFields: []string{"SOL_ID"}, Params: map[string]string{"AS_OF_DATE": "2024-03-31", "LIMIT": "5"}}
+	proc := &ProcedureConfig{Name: "P", Signature: []ProcParam{
+		{Name: "SOL_ID", Direction: "in", Type: "varchar2"},
+		{Name: "AS_OF_DATE", Direction: "in", Type: "varchar2"},
+		{Name: "LIMIT", Direction: "in_out", Type: "number"},
+		{Name: "P_DONE", Direction: "out", Type: "date"},
+	}}
+	args, outs, err := signatureArgs(cfg, proc, "0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := describeArgs(args); got != "[SOL_ID=0001, AS_OF_DATE=2024-03-31, LIMIT=IN OUT 5, P_DONE=OUT]" {
+		t.Errorf("signatureArgs bound %s", got)
+	}
+	if len(outs) != 2 || outs[0].param.Name != "LIMIT" || outs[1].param.Name != "P_DONE" {
+		t.Fatalf("OUT arguments %v, want LIMIT and P_DONE", outs)
+	}
+	if out := args[2].(sql.NamedArg).Value.(sql.Out); !out.In || out.Dest != outs[0].dest {
+		t.Errorf("LIMIT bound as %+v, want IN OUT to its outValue", out)
+	}
+
+	proc.Signature = append(proc.Signature, ProcParam{Name: "MISSING", Direction: "in", Type: "varchar2"})
+	if _, _, err := signatureArgs(cfg, proc, "0001"); err == nil || !strings.Contains(err.Error(), "argument MISSING") {
+		t.Errorf("error %v for an argument without a value", err)
+	}
+}
+
+func TestCheckOutValues(t *testing.T) {
+	out := func(name, typ string, dest interface{}) outValue {
+		return outValue{ProcParam{Name: name, Direction: "out", Type: typ}, dest}
+	}
+	str := func(s string) *string { return &s }
+	num := func(s string) *godror.Number { n := godror.Number(s); return &n }
+	done := time.Date(2024, 3, 31, 23, 5, 0, 0, time.UTC)
+	proc := &ProcedureConfig{Name: "P", StatusParam: "P_STATUS", SuccessValues: []string{"0", "W"},
+		MessageParam: "P_MSG", RowsParam: "P_ROWS"}
+	tests := []struct {
+		name   string
+		outs   []outValue
+		values string
+		rows   int64
+		err    string
+	}{
+		{"success", []outValue{out("P_STATUS", "varchar2", str("0")), out("P_MSG", "varchar2", str("")),
+			out("P_ROWS", "number", num("42")), out("P_DONE", "date", &done)},
+			"P_STATUS=0;P_MSG=;P_ROWS=42;P_DONE=2024-03-31 23:05:00", 42, ""},
+		{"other success value", []outValue{out("P_STATUS", "varchar2", str("W")), out("P_DONE", "date", new(time.Time))},
+			"P_STATUS=W;P_DONE=", 0, ""},
+		{"failure with message", []outValue{out("P_STATUS", "varchar2", str("E")), out("P_MSG", "varchar2", str("no rates")),
+			out("P_ROWS", "number", num("0"))},
+			"P_STATUS=E;P_MSG=no rates;P_ROWS=0", 0, "P returned P_STATUS=E: no rates"},
+		{"failure without message", []outValue{out("P_STATUS", "varchar2", str("9"))},
+			"P_STATUS=9", 0, "P returned P_STATUS=9"},
+	}
+	for _, tt := range tests {
+		res, err := checkOutValues(proc, tt.outs)
+		if res.OutValues != tt.values || res.Rows != tt.rows {
+			t.Errorf("%s: OUT values %q and %d rows, want %q and %d", tt.name, res.OutValues, res.Rows, tt.values, tt.rows)
+		}
+		if (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
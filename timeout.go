@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// procTimeout returns the execution limit of a single call of proc, or zero
+// when it may run for as long as the run allows.
+func procTimeout(proc *ProcedureConfig) time.Duration {
+	return time.Duration(proc.TimeoutSeconds) * time.Second
+}
+
+// runWithTimeout runs fn under the given timeout. godror breaks the call on the
+// server once the context expires. timedOut reports whether the deadline, the
+// procedure's own or the run's, is what stopped fn.
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(context.Context) error) (timedOut bool, err error) {
+	callCtx, cancel := ctx, context.CancelFunc(func() {})
+	if timeout > 0 {
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	err = fn(callCtx)
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		return true, fmt.Errorf("timed out: %w: %w", context.DeadlineExceeded, err)
+	}
+	return false, err
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// transactionTasks makes one task per SOL that runs all of procs for it in a
+// single transaction.
+func transactionTasks(sols []string, procs []ProcedureConfig) []task {
+	tasks := make([]task, len(sols))
+	for i, solID := range sols {
+		tasks[i] = task{SolID: solID, Procs: procs}
+	}
+	return tasks
+}
+
+// runSolTransaction runs a SOL's procedures in order on one connection inside
+// one transaction, committing only when every one of them succeeds. A failure
+// rolls back the whole SOL and skips the procedures after it; transient errors
+// retry the whole transaction. Procedures completed in a previous run are not
+// repeated.
+func (r *taskRunner) runSolTransaction(ctx context.Context, t task) string {
+	solID := t.SolID
+	var procs []ProcedureConfig
+	for _, proc := range t.Procs {
+		if prev, ok := r.cp.completed(solID, proc.Name); ok {
+			log.Printf("⏭️ Skipping %s for SOL %s, completed in a previous run", proc.Name, solID)
+			recordSummary(r.mu, r.summary, prev)
+			r.progress()
+			continue
+		}
+		procs = append(procs, proc)
+	}
+	if len(procs) == 0 {
+		return "SUCCESS"
+	}
+
+	log.Printf("🔁 Inserting: %d procedure(s) for SOL %s in one transaction", len(procs), solID)
+	var logs []ProcLog
+	attempt := 0
+	// A lost commit leaves the transaction's outcome unknown, so it is only
+	// replayed when every procedure in it is idempotent.
+	replaySafe := true
+	for _, proc := range procs {
+		replaySafe = replaySafe && proc.Idempotent
+	}
+	attempts, err := withRetry(ctx, r.cfg.Retry, fmt.Sprintf("transaction for SOL %s", solID), replaySafe, func() error {
+		var err error
+		attempt++
+		logs, err = r.solTransaction(ctx, solID, procs, attempt)
+		return err
+	})
+
+	status := "SUCCESS"
+	for _, plog := range logs {
+		plog.Attempts = attempts
+		r.logCh <- plog
+		if plog.Status == "SUCCESS" {
+			if err := r.cp.record(plog); err != nil {
+				log.Printf("⚠️ Failed to checkpoint %s for SOL %s: %v", plog.Procedure, solID, err)
+			}
+		}
+		recordSummary(r.mu, r.summary, plog)
+		if statusRank[plog.Status] > statusRank[status] {
+			status = plog.Status
+		}
+		r.progress()
+	}
+	if err != nil {
+		log.Printf("↩️ Rolled back SOL %s: %v", solID, err)
+	}
+	return status
+}
+
+// solTransaction makes one attempt at a SOL's transaction and returns a log
+// entry per procedure.
+func (r *taskRunner) solTransaction(ctx context.Context, solID string, procs []ProcedureConfig, attempt int) ([]ProcLog, error) {
+	now := time.Now()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logs := make([]ProcLog, len(procs))
+		for i, proc := range procs {
+			logs[i] = ProcLog{SolID: solID, Procedure: proc.Name, StartTime: now, EndTime: now,
+				Status: procStatus(ctx, err, false), ErrorDetails: "starting transaction: " + err.Error()}
+		}
+		return logs, err
+	}
+
+	logs := make([]ProcLog, 0, len(procs))
+	var failed error
+	for i := range procs {
+		proc := &procs[i]
+		start := time.Now()
+		if failed != nil {
+			logs = append(logs, ProcLog{SolID: solID, Procedure: proc.Name, StartTime: start, EndTime: start,
+				Status: "SKIPPED", ErrorDetails: "not run: an earlier procedure of the SOL failed"})
+			continue
+		}
+		var res callResult
+		timedOut, err := runWithTimeout(ctx, procTimeout(proc), func(ctx context.Context) error {
+			var err error
+			res, err = execProcedure(ctx, tx, r.cfg, proc, solID)
+			return err
+		})
+		if r.output != nil && res.Output != "" {
+			r.output.write(solID, proc.Name, attempt, res.Output)
+		}
+		end := time.Now()
+		plog := ProcLog{
+			SolID:         solID,
+			Procedure:     proc.Name,
+			StartTime:     start,
+			EndTime:       end,
+			ExecutionTime: end.Sub(start),
+			Rows:          res.Rows,
+			OutValues:     res.OutValues,
+			Status:        procStatus(ctx, err, timedOut),
+		}
+		if err != nil {
+			plog.ErrorDetails = err.Error()
+			failed = fmt.Errorf("%s: %w", proc.Name, err)
+		}
+		logs = append(logs, plog)
+	}
+
+	outcome := "COMMITTED"
+	if failed == nil {
+		if err := tx.Commit(); err != nil {
+			failed = fmt.Errorf("commit: %w", err)
+			for i := range logs {
+				logs[i].Status = "FAIL"
+				logs[i].ErrorDetails = failed.Error()
+			}
+		}
+	} else {
+		tx.Rollback()
+	}
+	if failed != nil {
+		// Work that succeeded was undone with the rest of the SOL.
+		outcome = "ROLLED_BACK"
+		for i := range logs {
+			if logs[i].Status == "SUCCESS" {
+				logs[i].Status = "ROLLED_BACK"
+			}
+		}
+	}
+	for i := range logs {
+		logs[i].Transaction = outcome
+	}
+	return logs, failed
+}
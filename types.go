@@ -10,12 +10,36 @@ type ProcLog struct {
 	ExecutionTime time.Duration
 	Status        string
 	ErrorDetails  string
+	Attempts      int
+	Rows          int64
+	Bytes         int64
+	OutValues     string
+	// Transaction is COMMITTED or ROLLED_BACK when the SOL ran in one
+	// transaction.
+	Transaction string
 }
 
 type ColumnConfig struct {
 	Name   string
 	Length int
 	Align  string
+	// Type overrides the column's database type for scanning and
+	// formatting: string, number, date or boolean. Format is the Go time
+	// layout of dates, Decimals the number of decimal places numbers are
+	// rounded to (-1 keeps them as they are) and Timezone the zone dates are
+	// written in.
+	Type     string
+	Format   string
+	Decimals int
+	Timezone string
+	location *time.Location
+	// Pad, ImpliedScale and Sign shape numbers in fixed-width output;
+	// ImpliedScale (-1 when unset) rounds to that many places and leaves out
+	// the decimal point. Rounding is half_up, half_even, down or up.
+	Pad          string
+	ImpliedScale int
+	Sign         string
+	Rounding     string
 }
 
 type ProcSummary struct {
@@ -23,4 +47,15 @@ type ProcSummary struct {
 	StartTime time.Time
 	EndTime   time.Time
 	Status    string
+	Runs      int
+	Timeouts  int
+	Succeeded int
+	Failed    int
+	Skipped   int
+	// Committed and RolledBack count SOL transactions the procedure was part of.
+	Committed  int
+	RolledBack int
+	Rows       int64
+	Bytes      int64
+	Durations  []time.Duration
 }
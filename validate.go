@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// validateTemplates checks every procedure's template against the database:
+// columns missing from the source, fixed-width lengths too short for the
+// column's data as formatted, unsupported or mismatched types and invalid
+// alignments. Procedures read through a SQL file are checked against the
+// query's result columns by position. It returns the number of problems found.
+func validateTemplates(ctx context.Context, w io.Writer, db *sql.DB, cfg *ExtractionConfig, sols []string, templates map[string][]ColumnConfig, queries map[string]string) int {
+	problems := 0
+	for _, proc := range cfg.Procedures {
+		issues := validateProcedure(ctx, db, cfg, &proc, sols, templates[proc.Name], queries)
+		if len(issues) == 0 {
+			fmt.Fprintf(w, "✅ %s: template OK (%d columns)\n", proc.Name, len(templates[proc.Name]))
+			continue
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(w, "❌ %s: %s\n", proc.Name, issue)
+		}
+		problems += len(issues)
+	}
+	fmt.Fprintf(w, "Validated %d procedure(s), %d problem(s) found\n", len(cfg.Procedures), problems)
+	return problems
+}
+
+func validateProcedure(ctx context.Context, db *sql.DB, cfg *ExtractionConfig, proc *ProcedureConfig, sols []string, cols []ColumnConfig, queries map[string]string) []string {
+	var issues []string
+	for _, col := range cols {
+		if col.Align != "" && col.Align != "left" && col.Align != "right" {
+			issues = append(issues, fmt.Sprintf("column %s has invalid align %q (use left or right)", col.Name, col.Align))
+		}
+		if proc.Format == "fixed" && col.Length <= 0 {
+			issues = append(issues, fmt.Sprintf("column %s has no length for fixed-width output", col.Name))
+		}
+	}
+
+	dbCols, err := sourceColumns(ctx, db, cfg, proc, sols, queries)
+	if err != nil {
+		return append(issues, err.Error())
+	}
+
+	if _, ok := queries[proc.Name]; ok {
+		if len(dbCols) != len(cols) {
+			return append(issues, fmt.Sprintf("query returns %d columns but template defines %d", len(dbCols), len(cols)))
+		}
+		for i, col := range cols {
+			issues = append(issues, checkColumn(proc, col, dbCols[i])...)
+		}
+		return issues
+	}
+
+	byName := make(map[string]dbColumn, len(dbCols))
+	for _, c := range dbCols {
+		byName[c.Name] = c
+	}
+	for _, col := range cols {
+		dbCol, ok := byName[strings.ToUpper(col.Name)]
+		if !ok {
+			issues = append(issues, fmt.Sprintf("column %s not found in %s", col.Name, proc.Source))
+			continue
+		}
+		issues = append(issues, checkColumn(proc, col, dbCol)...)
+	}
+	return issues
+}
+
+func checkColumn(proc *ProcedureConfig, col ColumnConfig, dbCol dbColumn) []string {
+	var issues []string
+	if !dbCol.supportedType() {
+		issues = append(issues, fmt.Sprintf("column %s has unsupported type %s", col.Name, dbCol.DataType))
+	}
+	if col.Type == "date" && !dbCol.isTemporal() {
+		issues = append(issues, fmt.Sprintf("column %s is declared as a date but %s is not a date or timestamp type", col.Name, dbCol.DataType))
+	}
+	if proc.Format == "fixed" && col.Length > 0 {
+		if width := col.textWidth(dbCol); width > col.Length {
+			issues = append(issues, fmt.Sprintf("column %s has length %d but %s data can be %d characters wide",
+				col.Name, col.Length, dbCol.DataType, width))
+		}
+	}
+	return issues
+}
@@ -4,13 +4,22 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"math"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
-// Write procedure logs to CSV file
-func writeLog(path string, logCh <-chan ProcLog) {
-	file, err := os.Create(path)
+// Write procedure logs to CSV file, appending to the existing log when resuming
+func writeLog(path string, logCh <-chan ProcLog, params string, resume bool) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(path, flags, 0644)
 	if err != nil {
 		log.Fatalf("Failed to create procedure log file: %v", err)
 	}
@@ -19,14 +28,24 @@ func writeLog(path string, logCh <-chan ProcLog) {
 	writer := csv.NewWriter(file)
 	defer writer.Flush()
 
-	// Write header
-	writer.Write([]string{"SOL_ID", "PROCEDURE", "START_TIME", "END_TIME", "EXECUTION_SECONDS", "STATUS", "ERROR_DETAILS"})
+	// Write header unless appending to an existing log
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		writer.Write([]string{"SOL_ID", "PROCEDURE", "START_TIME", "END_TIME", "EXECUTION_SECONDS", "STATUS", "ATTEMPTS", "ROWS", "BYTES", "ERROR_DETAILS", "OUT_VALUES", "TRANSACTION", "PARAMS"})
+	}
 
 	for plog := range logCh {
 		errDetails := plog.ErrorDetails
 		if errDetails == "" {
 			errDetails = "-"
 		}
+		outValues := plog.OutValues
+		if outValues == "" {
+			outValues = "-"
+		}
+		transaction := plog.Transaction
+		if transaction == "" {
+			transaction = "-"
+		}
 		timeFormat := "02-01-2006 15:04:05"
 		record := []string{
 			plog.SolID,
@@ -35,14 +54,118 @@ func writeLog(path string, logCh <-chan ProcLog) {
 			plog.EndTime.Format(timeFormat),
 			fmt.Sprintf("%.3f", plog.ExecutionTime.Seconds()),
 			plog.Status,
+			strconv.Itoa(plog.Attempts),
+			strconv.FormatInt(plog.Rows, 10),
+			strconv.FormatInt(plog.Bytes, 10),
 			errDetails,
+			outValues,
+			transaction,
+			params,
 		}
 		writer.Write(record)
 	}
 }
 
+// recordSummary folds one procedure result into the per-procedure summary.
+func recordSummary(mu *sync.Mutex, summary map[string]ProcSummary, plog ProcLog) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, exists := summary[plog.Procedure]
+	if !exists {
+		s = ProcSummary{Procedure: plog.Procedure, StartTime: plog.StartTime, EndTime: plog.EndTime, Status: plog.Status}
+	} else {
+		if plog.StartTime.Before(s.StartTime) {
+			s.StartTime = plog.StartTime
+		}
+		if plog.EndTime.After(s.EndTime) {
+			s.EndTime = plog.EndTime
+		}
+		if statusRank[plog.Status] > statusRank[s.Status] {
+			s.Status = plog.Status
+		}
+	}
+	s.Runs++
+	if plog.Status == "TIMEOUT" {
+		s.Timeouts++
+	}
+	switch plog.Transaction {
+	case "COMMITTED":
+		s.Committed++
+	case "ROLLED_BACK":
+		s.RolledBack++
+	}
+	switch plog.Status {
+	case "SUCCESS":
+		s.Succeeded++
+	case "SKIPPED":
+		// Never started, so it has no duration to add.
+		s.Skipped++
+		summary[plog.Procedure] = s
+		return
+	default:
+		s.Failed++
+	}
+	s.Rows += plog.Rows
+	s.Bytes += plog.Bytes
+	s.Durations = append(s.Durations, plog.ExecutionTime)
+	summary[plog.Procedure] = s
+}
+
+// statusRank orders statuses by severity; a procedure's summary status is the
+// most severe status of any of its runs.
+var statusRank = map[string]int{
+	"SUCCESS":     0,
+	"SKIPPED":     1,
+	"ROLLED_BACK": 2,
+	"CANCELLED":   3,
+	"TIMEOUT":     4,
+	"FAIL":        5,
+}
+
+// expectedRuns is the number of times a procedure runs: once for each SOL,
+// or once for a global procedure.
+func expectedRuns(p ProcedureConfig, solCount int) int {
+	if p.Global {
+		return 1
+	}
+	return solCount
+}
+
+// procComplete reports whether a procedure ran and succeeded for every SOL, so
+// that its spools make up its whole output.
+func procComplete(summary map[string]ProcSummary, p ProcedureConfig, solCount int) bool {
+	s, expected := summary[p.Name], expectedRuns(p, solCount)
+	return s.Runs == expected && s.Succeeded == expected
+}
+
+// markUnfinished flags every procedure that did not run for all of its expected
+// SOLs as CANCELLED, adding summary entries for procedures that never started.
+func markUnfinished(summary map[string]ProcSummary, procs []ProcedureConfig, solCount int) {
+	for _, p := range procs {
+		expected := expectedRuns(p, solCount)
+		s, exists := summary[p.Name]
+		if !exists {
+			s = ProcSummary{Procedure: p.Name}
+		}
+		if s.Runs < expected && statusRank[s.Status] < statusRank["CANCELLED"] {
+			s.Status = "CANCELLED"
+		}
+		summary[p.Name] = s
+	}
+}
+
+// allSucceeded reports whether every procedure in the summary finished without failure.
+func allSucceeded(summary map[string]ProcSummary) bool {
+	for _, s := range summary {
+		if s.Status != "SUCCESS" {
+			return false
+		}
+	}
+	return true
+}
+
 // Write procedure summary CSV after all executions
-func writeSummary(path string, summary map[string]ProcSummary) {
+func writeSummary(path string, summary map[string]ProcSummary, params string) {
 	file, err := os.Create(path)
 	if err != nil {
 		log.Printf("Failed to create procedure summary file: %v", err)
@@ -54,7 +177,9 @@ func writeSummary(path string, summary map[string]ProcSummary) {
 	defer writer.Flush()
 
 	// Header
-	writer.Write([]string{"PROCEDURE", "EARLIEST_START_TIME", "LATEST_END_TIME", "EXECUTION_SECONDS", "STATUS"})
+	writer.Write([]string{"PROCEDURE", "EARLIEST_START_TIME", "LATEST_END_TIME", "EXECUTION_SECONDS", "STATUS",
+		"SOLS_SUCCEEDED", "SOLS_FAILED", "SOLS_SKIPPED", "TIMEOUTS", "TX_COMMITTED", "TX_ROLLED_BACK", "TOTAL_ROWS", "TOTAL_BYTES",
+		"MIN_SECONDS", "AVG_SECONDS", "P95_SECONDS", "MAX_SECONDS", "PARAMS"})
 
 	// Sort procedures alphabetically
 	var procs []string
@@ -65,14 +190,58 @@ func writeSummary(path string, summary map[string]ProcSummary) {
 
 	for _, p := range procs {
 		s := summary[p]
+		stats := summarizeDurations(s.Durations)
 		execSeconds := s.EndTime.Sub(s.StartTime).Seconds()
 		timeFormat := "02-01-2006 15:04:05"
+		startTime, endTime := "-", "-"
+		if !s.StartTime.IsZero() {
+			startTime = s.StartTime.Format(timeFormat)
+			endTime = s.EndTime.Format(timeFormat)
+		}
 		writer.Write([]string{
 			p,
-			s.StartTime.Format(timeFormat),
-			s.EndTime.Format(timeFormat),
+			startTime,
+			endTime,
 			fmt.Sprintf("%.3f", execSeconds),
 			s.Status,
+			strconv.Itoa(s.Succeeded),
+			strconv.Itoa(s.Failed),
+			strconv.Itoa(s.Skipped),
+			strconv.Itoa(s.Timeouts),
+			strconv.Itoa(s.Committed),
+			strconv.Itoa(s.RolledBack),
+			strconv.FormatInt(s.Rows, 10),
+			strconv.FormatInt(s.Bytes, 10),
+			fmt.Sprintf("%.3f", stats.min.Seconds()),
+			fmt.Sprintf("%.3f", stats.avg.Seconds()),
+			fmt.Sprintf("%.3f", stats.p95.Seconds()),
+			fmt.Sprintf("%.3f", stats.max.Seconds()),
+			params,
 		})
 	}
 }
+
+type durationStats struct {
+	min, avg, p95, max time.Duration
+}
+
+// summarizeDurations returns the min, mean, 95th percentile (nearest rank) and
+// max of a procedure's per-SOL execution times.
+func summarizeDurations(durations []time.Duration) durationStats {
+	if len(durations) == 0 {
+		return durationStats{}
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return durationStats{
+		min: sorted[0],
+		avg: total / time.Duration(len(sorted)),
+		p95: sorted[rank],
+		max: sorted[len(sorted)-1],
+	}
+}
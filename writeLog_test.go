@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcComplete(t *testing.T) {
+	procs := []ProcedureConfig{{Name: "DONE"}, {Name: "FAILED"}, {Name: "CUT"}, {Name: "UNSTARTED"}, {Name: "G", Global: true}}
+	summary := map[string]ProcSummary{
+		"DONE":   {Procedure: "DONE", Status: "SUCCESS", Runs: 3, Succeeded: 3},
+		"FAILED": {Procedure: "FAILED", Status: "FAIL", Runs: 3, Succeeded: 2, Failed: 1},
+		// A failure followed by a shutdown: the status stays FAIL although
+		// the last SOL never ran.
+		"CUT": {Procedure: "CUT", Status: "FAIL", Runs: 2, Succeeded: 1, Failed: 1},
+		"G":   {Procedure: "G", Status: "SUCCESS", Runs: 1, Succeeded: 1},
+	}
+	markUnfinished(summary, procs, 3)
+	want := map[string]bool{"DONE": true, "FAILED": false, "CUT": false, "UNSTARTED": false, "G": true}
+	for _, p := range procs {
+		if got := procComplete(summary, p, 3); got != want[p.Name] {
+			t.Errorf("procComplete(%s) = %v, want %v", p.Name, got, want[p.Name])
+		}
+	}
+	if got := summary["UNSTARTED"].Status; got != "CANCELLED" {
+		t.Errorf("UNSTARTED marked %q, want CANCELLED", got)
+	}
+}
+
+func TestRecordSummary(t *testing.T) {
+	start := time.Date(2024, 3, 31, 10, 0, 0, 0, time.UTC)
+	logs := []ProcLog{
+		{Procedure: "P", SolID: "02", StartTime: start.Add(time.Minute), EndTime: start.Add(3 * time.Minute),
+			ExecutionTime: 2 * time.Minute, Status: "SUCCESS", Rows: 10, Bytes: 200},
+		{Procedure: "P", SolID: "01", StartTime: start, EndTime: start.Add(time.Minute),
+			ExecutionTime: time.Minute, Status: "TIMEOUT", Rows: 4, Bytes: 80, Transaction: "ROLLED_BACK"},
+		{Procedure: "P", SolID: "03", StartTime: start.Add(5 * time.Minute), EndTime: start.Add(5 * time.Minute),
+			Status: "SKIPPED"},
+		{Procedure: "P", SolID: "04", StartTime: start.Add(2 * time.Minute), EndTime: start.Add(4 * time.Minute),
+			ExecutionTime: 2 * time.Minute, Status: "SUCCESS", Rows: 1, Bytes: 20, Transaction: "COMMITTED"},
+	}
+	var mu sync.Mutex
+	summary := make(map[string]ProcSummary)
+	for _, l := range logs {
+		recordSummary(&mu, summary, l)
+	}
+	s := summary["P"]
+	if s.Runs != 4 || s.Succeeded != 2 || s.Failed != 1 || s.Skipped != 1 || s.Timeouts != 1 {
+		t.Errorf("runs %d, succeeded %d, failed %d, skipped %d, timeouts %d; want 4, 2, 1, 1, 1",
+			s.Runs, s.Succeeded, s.Failed, s.Skipped, s.Timeouts)
+	}
+	if s.Rows != 15 || s.Bytes != 300 {
+		t.Errorf("%d rows and %d bytes, want 15 and 300", s.Rows, s.Bytes)
+	}
+	if s.Committed != 1 || s.RolledBack != 1 {
+		t.Errorf("%d committed and %d rolled back, want 1 and 1", s.Committed, s.RolledBack)
+	}
+	if s.Status != "TIMEOUT" {
+		t.Errorf("status %s, want the most severe, TIMEOUT", s.Status)
+	}
+	if !s.StartTime.Equal(start) || !s.EndTime.Equal(start.Add(5*time.Minute)) {
+		t.Errorf("span %s to %s, want the earliest start and latest end", s.StartTime, s.EndTime)
+	}
+	if len(s.Durations) != 3 {
+		t.Errorf("%d durations, want 3 (none for the skipped run)", len(s.Durations))
+	}
+}
+
+func TestSummarizeDurations(t *testing.T) {
+	var durations []time.Duration
+	for i := 20; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Second)
+	}
+	tests := []struct {
+		durations []time.Duration
+		want      durationStats
+	}{
+		{nil, durationStats{}},
+		{[]time.Duration{3 * time.Second}, durationStats{3 * time.Second, 3 * time.Second, 3 * time.Second, 3 * time.Second}},
+		{[]time.Duration{4 * time.Second, time.Second}, durationStats{time.Second, 2500 * time.Millisecond, 4 * time.Second, 4 * time.Second}},
+		// The 95th percentile of 20 values is the 19th by nearest rank.
+		{durations, durationStats{time.Second, 10500 * time.Millisecond, 19 * time.Second, 20 * time.Second}},
+	}
+	for _, tt := range tests {
+		if got := summarizeDurations(tt.durations); got != tt.want {
+			t.Errorf("summarizeDurations(%v) = %+v, want %+v", tt.durations, got, tt.want)
+		}
+	}
+	if durations[0] != 20*time.Second {
+		t.Error("summarizeDurations sorted its argument")
+	}
+}